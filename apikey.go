@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/mtp721/micobo-assignment/pkg/auth"
+	"github.com/mtp721/micobo-assignment/pkg/db"
+	"github.com/mtp721/micobo-assignment/pkg/models"
+	"github.com/mtp721/micobo-assignment/pkg/repository"
+	"github.com/mtp721/micobo-assignment/pkg/repository/postgres"
+)
+
+const apikeyUsage = `usage: apikey create [-role ROLE] [-employee ID] NAME|revoke ID
+  create  creates an API key named NAME and prints it, the key can't be shown again.
+          ROLE is admin, hr, event-organizer or employee (default employee), ID is the
+          employee the key belongs to, employees may only edit their own record and registrations
+  revoke  revokes the API key with the id ID`
+
+// runs the apikey subcommand with its arguments and returns the exit code
+func runAPIKey(cfg db.Config, args []string) int {
+	if len(args) == 0 || (args[0] != "create" && args[0] != "revoke") {
+		fmt.Fprintln(os.Stderr, apikeyUsage)
+		return 2
+	}
+	command := args[0]
+	fs := flag.NewFlagSet("apikey "+command, flag.ContinueOnError)
+	fs.Usage = func() { fmt.Fprintln(os.Stderr, apikeyUsage) }
+	role := auth.RoleEmployee
+	var employeeID int
+	if command == "create" {
+		fs.StringVar(&role, "role", role, "role of the key")
+		fs.IntVar(&employeeID, "employee", 0, "id of the employee the key belongs to")
+	}
+	if err := fs.Parse(args[1:]); err != nil || fs.NArg() != 1 {
+		if err == nil {
+			fs.Usage()
+		}
+		return 2
+	}
+	if !auth.ValidRole(role) {
+		fmt.Fprintf(os.Stderr, "invalid role %q, expected one of %s\n", role, strings.Join(auth.Roles, ", "))
+		return 2
+	}
+	arg := fs.Arg(0)
+
+	if err := cfg.Load(); err != nil {
+		fmt.Fprintf(os.Stderr, "loading database config: %v\n", err)
+		return 1
+	}
+	ctx := context.Background()
+	conn, err := db.Connect(ctx, cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer conn.Close()
+	store := postgres.New(conn)
+
+	switch command {
+	case "create":
+		var key string
+		if key, err = auth.GenerateAPIKey(); err != nil {
+			break
+		}
+		newKey := models.APIKey{Name: arg, Hash: auth.HashAPIKey(key), Role: role}
+		if employeeID != 0 {
+			newKey.EmployeeID = &employeeID
+		}
+		var created models.APIKey
+		if created, err = store.CreateAPIKey(ctx, newKey); err != nil {
+			break
+		}
+		fmt.Printf("created API key %d %q with role %s, send it in the X-API-Key header:\n%s\n", created.ID, created.Name, created.Role, key)
+	case "revoke":
+		id, convErr := strconv.Atoi(arg)
+		if convErr != nil {
+			fmt.Fprintf(os.Stderr, "invalid API key id %q\n", arg)
+			return 2
+		}
+		var revoked models.APIKey
+		if revoked, err = store.RevokeAPIKey(ctx, id); err != nil {
+			break
+		}
+		fmt.Printf("revoked API key %d %q\n", revoked.ID, revoked.Name)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "apikey %s: %v\n", command, err)
+		return 1
+	}
+	return 0
+}
+
+// name of the admin key created for the in-memory store
+const developmentKeyName = "development"
+
+// file the generated key of the in-memory store is written to, only the owner can read it
+const developmentKeyFile = ".memory-api-key"
+
+// creates the admin key in store and returns it, a key is generated if key is empty. The apikey subcommand
+// only manages the keys in postgres, so the in-memory store gets this key to call the API with while authentication is enabled
+func createDevelopmentKey(ctx context.Context, store repository.APIKeyRepository, key string) (string, error) {
+	if key == "" {
+		var err error
+		if key, err = auth.GenerateAPIKey(); err != nil {
+			return "", err
+		}
+	}
+	_, err := store.CreateAPIKey(ctx, models.APIKey{Name: developmentKeyName, Hash: auth.HashAPIKey(key), Role: auth.RoleAdmin})
+	return key, err
+}
+
+// writes key to a new file at path that only the owner can read, a file left from an earlier run is replaced
+// as os.WriteFile would keep its permissions
+func writeKeyFile(path, key string) error {
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintln(file, key); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"crypto/rsa"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/mtp721/micobo-assignment/pkg/auth"
+)
+
+// config of the authentication, set with flags or environment variables
+type authConfig struct {
+	//disables authentication, only meant for local development
+	disabled bool
+	//HS256 secret, JWT_HS256_SECRET is only read from the environment so it doesn't show up in the process list
+	hs256Secret        string
+	rs256PublicKeyFile string
+	issuer             string
+	audience           string
+	leeway             time.Duration
+}
+
+// registers the flags of the auth config on fs, their defaults are read from the environment
+func (c *authConfig) registerFlags(fs *flag.FlagSet) {
+	c.hs256Secret = os.Getenv("JWT_HS256_SECRET")
+	fs.BoolVar(&c.disabled, "auth-disabled", os.Getenv("AUTH_DISABLED") == "true",
+		"accept requests without credentials, only for local development (env AUTH_DISABLED)")
+	fs.StringVar(&c.rs256PublicKeyFile, "jwt-rs256-public-key", os.Getenv("JWT_RS256_PUBLIC_KEY_FILE"),
+		"PEM file of the public key verifying RS256 tokens (env JWT_RS256_PUBLIC_KEY_FILE)")
+	fs.StringVar(&c.issuer, "jwt-issuer", os.Getenv("JWT_ISSUER"), "required iss claim of tokens (env JWT_ISSUER)")
+	fs.StringVar(&c.audience, "jwt-audience", os.Getenv("JWT_AUDIENCE"), "required aud claim of tokens (env JWT_AUDIENCE)")
+	fs.DurationVar(&c.leeway, "jwt-leeway", envDuration("JWT_LEEWAY", 30*time.Second),
+		"tolerated clock skew when checking the expiry of tokens (env JWT_LEEWAY)")
+}
+
+// returns the config of the authenticator, the RS256 public key is read from its file
+func (c authConfig) load() (auth.Config, error) {
+	config := auth.Config{Issuer: c.issuer, Audience: c.audience, Leeway: c.leeway}
+	if c.hs256Secret != "" {
+		if len(c.hs256Secret) < 32 {
+			return config, fmt.Errorf("JWT_HS256_SECRET must have at least 32 characters")
+		}
+		config.HS256Secret = []byte(c.hs256Secret)
+	}
+	if c.rs256PublicKeyFile != "" {
+		pem, err := os.ReadFile(c.rs256PublicKeyFile)
+		if err != nil {
+			return config, err
+		}
+		var key *rsa.PublicKey
+		if key, err = jwt.ParseRSAPublicKeyFromPEM(pem); err != nil {
+			return config, fmt.Errorf("reading %s: %w", c.rs256PublicKeyFile, err)
+		}
+		config.RS256PublicKey = key
+	}
+	return config, nil
+}
@@ -1,32 +1,197 @@
 package main
 
 import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
 	"github.com/gin-gonic/gin"
+	"github.com/mtp721/micobo-assignment/pkg/auth"
 	"github.com/mtp721/micobo-assignment/pkg/db"
 	"github.com/mtp721/micobo-assignment/pkg/handlers"
+	"github.com/mtp721/micobo-assignment/pkg/logging"
+	"github.com/mtp721/micobo-assignment/pkg/metrics"
+	"github.com/mtp721/micobo-assignment/pkg/repository"
+	"github.com/mtp721/micobo-assignment/pkg/repository/memory"
+	"github.com/mtp721/micobo-assignment/pkg/repository/postgres"
+)
+
+// storage backends selectable with -storage or STORAGE
+const (
+	storagePostgres = "postgres"
+	storageMemory   = "memory"
 )
 
 func main() {
-	//Init db
-	db := db.Init()
-	defer db.Close()
+	storage := flag.String("storage", envOr("STORAGE", storagePostgres),
+		"storage backend, postgres or memory (in-memory with seed data, for local development)")
+	readyTimeout := flag.Duration("ready-timeout", envDuration("READY_TIMEOUT", 2*time.Second),
+		"timeout of the database checks of /readyz (env READY_TIMEOUT)")
+	queryTimeout := flag.Duration("query-timeout", envDuration("QUERY_TIMEOUT", 5*time.Second),
+		"maximum time of the database queries of a request, 0 for no limit (env QUERY_TIMEOUT)")
+	var logLevel slog.Level
+	if err := logLevel.UnmarshalText([]byte(envOr("LOG_LEVEL", "info"))); err != nil {
+		fatal("invalid LOG_LEVEL", "error", err)
+	}
+	flag.TextVar(&logLevel, "log-level", logLevel, "minimum level of logged messages: debug, info, warn or error (env LOG_LEVEL)")
+	dbConfig := db.DefaultConfig()
+	dbConfig.RegisterFlags(flag.CommandLine)
+	var serverConfig serverConfig
+	serverConfig.registerFlags(flag.CommandLine)
+	var authConfig authConfig
+	authConfig.registerFlags(flag.CommandLine)
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] [migrate up|down [steps]|status | apikey create [-role ROLE] [-employee ID] NAME|revoke ID]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	//JSON logs with personal data redacted, also used by the packages logging with slog's default logger
+	logger := logging.New(os.Stderr, logLevel)
+	slog.SetDefault(logger)
+
+	switch flag.Arg(0) {
+	case "":
+	case "migrate":
+		os.Exit(runMigrate(dbConfig, flag.Args()[1:]))
+	case "apikey":
+		os.Exit(runAPIKey(dbConfig, flag.Args()[1:]))
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var store repository.Store
+	var conn *sql.DB
+	switch *storage {
+	case storagePostgres:
+		//Init db
+		if err := dbConfig.Load(); err != nil {
+			fatal("loading database config failed", "error", err)
+		}
+		var err error
+		if conn, err = db.Init(context.Background(), dbConfig); err != nil {
+			fatal("initializing database failed", "error", err)
+		}
+
+		pgStore := postgres.New(conn)
+		pgStore.QueryTimeout = *queryTimeout
+		store = pgStore
+	case storageMemory:
+		memStore := memory.New()
+		if err := memStore.Seed(context.Background()); err != nil {
+			fatal("seeding in-memory store failed", "error", err)
+		}
+		if !authConfig.disabled {
+			//MEMORY_API_KEY is only read from the environment so it doesn't show up in the process list
+			configuredKey := os.Getenv("MEMORY_API_KEY")
+			key, err := createDevelopmentKey(context.Background(), memStore, configuredKey)
+			if err != nil {
+				fatal("creating development API key failed", "error", err)
+			}
+			//a generated key is written to a file, stderr is where the logs are collected
+			if configuredKey == "" {
+				if err := writeKeyFile(developmentKeyFile, key); err != nil {
+					fatal("writing development API key failed", "error", err)
+				}
+				slog.Info("created the admin API key of the in-memory store, send it in the X-API-Key header", "file", developmentKeyFile)
+			}
+		}
+		store = memStore
+	default:
+		fatal("unknown storage, expected postgres or memory", "storage", *storage)
+	}
 
 	//handler object with handler methods
-	h := handlers.New(db)
+	h := handlers.New(store, store, store)
 
 	// API Endpoints
-	router := gin.Default()
-	router.GET("/employees", h.GetEmployees)          //get all employees
-	router.POST("/employees", h.PostEmployee)         //registers new employee
-	router.PUT("/employees/:id", h.PutEmployee)       //update employees info
-	router.DELETE("/employees/:id", h.DeleteEmployee) //delete specified employee
+	if os.Getenv(gin.EnvGinMode) == "" {
+		gin.SetMode(gin.ReleaseMode)
+	}
+	router := gin.New()
+	m := metrics.New()
+	if conn != nil {
+		m.RegisterDB(conn, storagePostgres)
+	}
+	router.Use(handlers.RequestID(logger), handlers.AccessLog(), m.Middleware(), handlers.Recovery())
+
+	//probes of the orchestrator
+	health := handlers.NewHealth(conn, *readyTimeout)
+	router.GET("/healthz", health.Healthz) //process is up
+	router.GET("/readyz", health.Readyz)   //database is reachable and migrated
+	router.GET("/metrics", m.Handler())    //metrics in the Prometheus text format
+
+	//all other routes require an API key or a bearer token
+	api := router.Group("/")
+	if authConfig.disabled {
+		slog.Warn("authentication is disabled, every client can access the API")
+	} else {
+		jwtConfig, err := authConfig.load()
+		if err != nil {
+			fatal("loading auth config failed", "error", err)
+		}
+		api.Use(handlers.Authenticate(auth.New(store, jwtConfig)), handlers.Authorize(auth.RoutePolicies))
+	}
 
-	router.GET("/events", h.GetEvents)    //get all upcoming events
-	router.GET("/events/:id", h.GetEvent) //get specific event
+	api.GET("/employees", h.GetEmployees)          //get all employees
+	api.POST("/employees", h.PostEmployee)         //registers new employee
+	api.PUT("/employees/:id", h.PutEmployee)       //replace employees info
+	api.PATCH("/employees/:id", h.PatchEmployee)   //update employees info with a merge patch
+	api.DELETE("/employees/:id", h.DeleteEmployee) //delete specified employee
+
+	api.POST("/employees/:id/restore", h.RestoreEmployee) //restore deleted employee
+	api.POST("/employees/:id/purge", h.PurgeEmployee)     //erase employee and their data in the audit log, admins only
+
+	//returns the events the employee is registered for, upcoming=true or past=true filter by date
+	api.GET("/employees/:id/events", h.GetEventsForEmployee)
+
+	api.GET("/events", h.GetEvents)          //get all upcoming events
+	api.GET("/events/:id", h.GetEvent)       //get specific event
+	api.POST("/events", h.PostEvent)         //registers new event
+	api.PUT("/events/:id", h.PutEvent)       //replace event info
+	api.PATCH("/events/:id", h.PatchEvent)   //update event info with a merge patch
+	api.DELETE("/events/:id", h.DeleteEvent) //delete specified event, cascade=true also removes its attendances
 
 	/*returns the list of the employees that are assisting to the event,
 	should accept query parameters for filtering if they need or don't need accommodation*/
-	router.GET("/events/:id/employees", h.GetEmployeesForEvent)
+	api.GET("/events/:id/employees", h.GetEmployeesForEvent)
+
+	api.POST("/events/:id/employees", h.PostAttendance)                 //register employee for event
+	api.PATCH("/events/:id/employees/:employeeId", h.PatchAttendance)   //set or toggle accommodation
+	api.DELETE("/events/:id/employees/:employeeId", h.DeleteAttendance) //withdraw employee from event
+
+	//changes to employees, events and attendances, entity and id filter by the changed entity
+	api.GET("/audit", handlers.NewAudit(store).GetAudit)
+
+	//serve until SIGINT or SIGTERM, in-flight requests are drained before the database is closed
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	err := serve(ctx, serverConfig, router)
+	if conn != nil {
+		conn.Close()
+	}
+	if err != nil {
+		fatal("server failed", "error", err)
+	}
+}
+
+// logs msg as error and exits
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
 
-	router.Run("localhost:8080")
+// returns the environment variable key, or fallback if it isn't set
+func envOr(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
 }
@@ -1,19 +1,43 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
 	"database/sql"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
 	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"regexp"
-	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/lib/pq"
+	"github.com/mtp721/micobo-assignment/pkg/auth"
+	pkgdb "github.com/mtp721/micobo-assignment/pkg/db"
 	"github.com/mtp721/micobo-assignment/pkg/handlers"
+	"github.com/mtp721/micobo-assignment/pkg/logging"
+	"github.com/mtp721/micobo-assignment/pkg/metrics"
 	"github.com/mtp721/micobo-assignment/pkg/models"
+	"github.com/mtp721/micobo-assignment/pkg/repository"
+	"github.com/mtp721/micobo-assignment/pkg/repository/memory"
+	"github.com/mtp721/micobo-assignment/pkg/repository/postgres"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -24,11 +48,43 @@ func newMock() (*sql.DB, sqlmock.Sqlmock) {
 	}
 	return db, mock
 }
+
+// expects the audit log entry of a change made without actor, it is written before the transaction is committed
+func expectAudit(mock sqlmock.Sqlmock, action, entity, entityID string) {
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO audit_log")).WithArgs(
+		"system", action, entity, entityID, sqlmock.AnyArg(), sqlmock.AnyArg(), "").WillReturnResult(sqlmock.NewResult(1, 1))
+}
+
+// parses a YYYY-MM-DD date for test data
+func date(s string) models.Date {
+	d, err := models.ParseDate(s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+// columns returned by the attendees query of GetEmployeesForEvent
+var attendeeColumns = []string{"id", "first_name", "last_name", "birthday", "gender", "deleted_at", "accommodation", "registered_at", "status"}
+
+// columns of the employees table
+var employeeColumns = []string{"id", "first_name", "last_name", "birthday", "gender", "deleted_at"}
+
+// columns of the attendances table returned by the attendance handlers
+var attendanceColumns = []string{"event_id", "employee_id", "accommodation", "registered_at", "status"}
+
+// registration time used for mocked attendances
+var registeredAt = time.Date(2022, 7, 1, 9, 0, 0, 0, time.UTC)
+
+// current time of the handlers' clock in tests that depend on the date
+var today = time.Date(2022, 7, 15, 12, 0, 0, 0, time.UTC)
+
 func TestGetEmployees(t *testing.T) {
 	//Init mock db
 	db, mock := newMock()
 
-	h := handlers.New(db)
+	store := postgres.New(db)
+	h := handlers.New(store, store, store)
 	//Init router
 	router := gin.Default()
 	router.GET("/employees", h.GetEmployees) //get all employees
@@ -37,10 +93,10 @@ func TestGetEmployees(t *testing.T) {
 	req, _ := http.NewRequest("GET", "/employees", nil)
 
 	//mock db should return this on specified query
-	rows := sqlmock.NewRows([]string{"id", "first_name", "last_name", "birthday", "gender"}).AddRow(
-		1, "Son", "Nong", "1999-05-19", "m").AddRow(2, "Max", "Mustermann", "1998-04-18", "m")
+	rows := sqlmock.NewRows(employeeColumns).AddRow(
+		1, "Son", "Nong", "1999-05-19", "m", nil).AddRow(2, "Max", "Mustermann", "1998-04-18", "m", nil)
 
-	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM employees ORDER BY id")).WillReturnRows(rows)
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM employees WHERE deleted_at IS NULL ORDER BY id")).WillReturnRows(rows)
 
 	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
@@ -79,7 +135,8 @@ func TestPostEmployee(t *testing.T) {
 	//Init mock db
 	db, mock := newMock()
 
-	h := handlers.New(db)
+	store := postgres.New(db)
+	h := handlers.New(store, store, store)
 	//Init router
 	router := gin.Default()
 	router.POST("/employees", h.PostEmployee) //registers new employee
@@ -91,11 +148,14 @@ func TestPostEmployee(t *testing.T) {
 	//mock db should return this on specified query
 	rows := sqlmock.NewRows([]string{"id"}).AddRow(3)
 
-	emp := models.Employee{ID: 3, FirstName: "Joe", LastName: "Jones", BirthDay: "1997-09-12", Gender: "m"}
+	emp := models.Employee{ID: 3, FirstName: "Joe", LastName: "Jones", BirthDay: date("1997-09-12"), Gender: "m"}
 
+	mock.ExpectBegin()
 	mock.ExpectQuery(regexp.QuoteMeta(
 		`INSERT INTO employees (first_name, last_name, birthday, gender) VALUES ($1, $2, $3, $4) RETURNING id`)).WithArgs(
 		emp.FirstName, emp.LastName, emp.BirthDay, emp.Gender).WillReturnRows(rows)
+	expectAudit(mock, "create", "employee", "3")
+	mock.ExpectCommit()
 
 	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
@@ -121,32 +181,37 @@ func TestPutEmployee(t *testing.T) {
 	//Init mock db
 	db, mock := newMock()
 
-	h := handlers.New(db)
+	store := postgres.New(db)
+	h := handlers.New(store, store, store)
 	//Init router
 	router := gin.Default()
-	router.PUT("/employees/:id", h.PutEmployee) //update employees info
+	router.PUT("/employees/:id", h.PutEmployee) //replace employees info
 
 	//http request
 	req, _ := http.NewRequest("PUT", "/employees/3", strings.NewReader(
-		`{"firstName": "Geo", "lastName": "Dude"}`))
+		`{"firstName": "Geo", "lastName": "Dude", "birthDay": "1997-09-12", "gender": "m"}`))
 
 	//employee to test
-	emp := models.Employee{ID: 3, FirstName: "Joe", LastName: "Jones", BirthDay: "1997-09-12", Gender: "m"}
+	emp := models.Employee{ID: 3, FirstName: "Joe", LastName: "Jones", BirthDay: date("1997-09-12"), Gender: "m"}
 	//employee after update
-	updEmp := models.Employee{ID: 3, FirstName: "Geo", LastName: "Dude", BirthDay: "1997-09-12", Gender: "m"}
+	updEmp := models.Employee{ID: 3, FirstName: "Geo", LastName: "Dude", BirthDay: date("1997-09-12"), Gender: "m"}
 
-	//row for select query
-	rows := sqlmock.NewRows([]string{"id", "first_name", "last_name", "birthday", "gender"}).AddRow(
-		emp.ID, emp.FirstName, emp.LastName, emp.BirthDay, emp.Gender)
 	//row after specified employee is updated in db
-	updRows := sqlmock.NewRows([]string{"id", "first_name", "last_name", "birthday", "gender"}).AddRow(
-		updEmp.ID, updEmp.FirstName, updEmp.LastName, updEmp.BirthDay, updEmp.Gender)
+	updRows := sqlmock.NewRows(employeeColumns).AddRow(
+		updEmp.ID, updEmp.FirstName, updEmp.LastName, updEmp.BirthDay.String(), updEmp.Gender, nil)
 
+	mock.ExpectBegin()
 	mock.ExpectQuery(regexp.QuoteMeta(
-		"SELECT * FROM employees WHERE id = $1")).WithArgs(strconv.Itoa(emp.ID)).WillReturnRows(rows)
+		"SELECT * FROM employees WHERE id = $1 AND deleted_at IS NULL FOR UPDATE")).WithArgs(emp.ID).WillReturnRows(
+		sqlmock.NewRows(employeeColumns).AddRow(
+			emp.ID, emp.FirstName, emp.LastName, emp.BirthDay.String(), emp.Gender, nil))
 	mock.ExpectQuery(regexp.QuoteMeta(
 		"UPDATE employees SET first_name = $1, last_name = $2, birthday = $3, gender = $4 WHERE id = $5 RETURNING *")).WithArgs(
-		updEmp.FirstName, updEmp.LastName, updEmp.BirthDay, updEmp.Gender, strconv.Itoa(updEmp.ID)).WillReturnRows(updRows)
+		updEmp.FirstName, updEmp.LastName, updEmp.BirthDay, updEmp.Gender, updEmp.ID).WillReturnRows(updRows)
+	//only the changed fields are recorded
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO audit_log")).WithArgs("system", "update", "employee", "3",
+		`{"firstName":"Joe","lastName":"Jones"}`, `{"firstName":"Geo","lastName":"Dude"}`, "").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
 
 	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
@@ -169,11 +234,175 @@ func TestPutEmployee(t *testing.T) {
 
 }
 
+// PUT replaces the employee, so fields left out of the body are rejected instead of keeping their value
+func TestPutEmployeeIncomplete(t *testing.T) {
+	//Init mock db
+	db, mock := newMock()
+
+	store := postgres.New(db)
+	h := handlers.New(store, store, store)
+	//Init router
+	router := gin.Default()
+	router.PUT("/employees/:id", h.PutEmployee) //replace employees info
+
+	//http request
+	req, _ := http.NewRequest("PUT", "/employees/3", strings.NewReader(
+		`{"firstName": "Geo", "lastName": "Dude"}`))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert := assert.New(t)
+	assert.Equal(http.StatusUnprocessableEntity, w.Code, "http Code doesn't match")
+	assert.Contains(w.Body.String(), `"invalid fields: birthDay, gender"`, "Response body doesn't match")
+
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPatchEmployee(t *testing.T) {
+	//Init mock db
+	db, mock := newMock()
+
+	store := postgres.New(db)
+	h := handlers.New(store, store, store)
+	//Init router
+	router := gin.Default()
+	router.PATCH("/employees/:id", h.PatchEmployee) //update employees info with a merge patch
+
+	//http request, the fields left out keep their value
+	req, _ := http.NewRequest("PATCH", "/employees/3", strings.NewReader(
+		`{"lastName": "Dude"}`))
+	req.Header.Set("Content-Type", "application/merge-patch+json")
+
+	//employee to test
+	emp := models.Employee{ID: 3, FirstName: "Joe", LastName: "Jones", BirthDay: date("1997-09-12"), Gender: "m"}
+	//employee after update
+	updEmp := models.Employee{ID: 3, FirstName: "Joe", LastName: "Dude", BirthDay: date("1997-09-12"), Gender: "m"}
+
+	//row for select query, the patch is applied to the row locked in the transaction
+	rows := sqlmock.NewRows(employeeColumns).AddRow(
+		emp.ID, emp.FirstName, emp.LastName, emp.BirthDay.String(), emp.Gender, nil)
+	//row after specified employee is updated in db
+	updRows := sqlmock.NewRows(employeeColumns).AddRow(
+		updEmp.ID, updEmp.FirstName, updEmp.LastName, updEmp.BirthDay.String(), updEmp.Gender, nil)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(
+		"SELECT * FROM employees WHERE id = $1 AND deleted_at IS NULL FOR UPDATE")).WithArgs(emp.ID).WillReturnRows(rows)
+	mock.ExpectQuery(regexp.QuoteMeta(
+		"UPDATE employees SET first_name = $1, last_name = $2, birthday = $3, gender = $4 WHERE id = $5 RETURNING *")).WithArgs(
+		updEmp.FirstName, updEmp.LastName, updEmp.BirthDay, updEmp.Gender, updEmp.ID).WillReturnRows(updRows)
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO audit_log")).WithArgs("system", "update", "employee", "3",
+		`{"lastName":"Jones"}`, `{"lastName":"Dude"}`, "").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	expectedResp := `{
+			"id": 3,
+			"firstName": "Joe",
+			"lastName": "Dude",
+			"birthDay": "1997-09-12",
+			"gender": "m"
+		}`
+	assert := assert.New(t)
+	assert.Equal(http.StatusOK, w.Code, "http Code doesn't match")
+	assert.JSONEq(expectedResp, w.Body.String(), "Response body doesn't match")
+
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// An employee deleted before the update is locked is reported as not found and nothing is written
+func TestPutEmployeeDeleted(t *testing.T) {
+	//Init mock db
+	db, mock := newMock()
+
+	store := postgres.New(db)
+	h := handlers.New(store, store, store)
+	//Init router
+	router := gin.Default()
+	router.PUT("/employees/:id", h.PutEmployee)     //replace employees info
+	router.PATCH("/employees/:id", h.PatchEmployee) //update employees info with a merge patch
+
+	for _, tc := range []struct {
+		method, body string
+	}{
+		{"PUT", `{"firstName": "Geo", "lastName": "Dude", "birthDay": "1997-09-12", "gender": "m"}`},
+		{"PATCH", `{"lastName": "Dude"}`},
+	} {
+		req, _ := http.NewRequest(tc.method, "/employees/3", strings.NewReader(tc.body))
+		req.Header.Set("Content-Type", "application/merge-patch+json")
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(
+			"SELECT * FROM employees WHERE id = $1 AND deleted_at IS NULL FOR UPDATE")).WithArgs(3).WillReturnError(sql.ErrNoRows)
+		mock.ExpectRollback()
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert := assert.New(t)
+		assert.Equal(http.StatusNotFound, w.Code, "http Code doesn't match for %s", tc.method)
+		assert.Contains(w.Body.String(), `"employee not found"`, "Response body doesn't match for %s", tc.method)
+	}
+
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// Merge patches are validated like PUT bodies after they are applied
+func TestPatchInvalid(t *testing.T) {
+	store := memory.New()
+	assert := assert.New(t)
+	assert.NoError(store.Seed(context.Background()))
+	h := handlers.New(store, store, store)
+	//Init router
+	router := gin.Default()
+	router.PATCH("/employees/:id", h.PatchEmployee) //update employees info with a merge patch
+	router.PATCH("/events/:id", h.PatchEvent)       //update event info with a merge patch
+
+	for _, tc := range []struct {
+		url, contentType, body string
+		expectedCode           int
+	}{
+		{"/employees/1", "application/json", `{"lastName": "Dude"}`, http.StatusUnsupportedMediaType},
+		{"/employees/1", "application/merge-patch+json", `{"lastName": null}`, http.StatusUnprocessableEntity},
+		{"/employees/1", "application/merge-patch+json", `{"gender": "x"}`, http.StatusUnprocessableEntity},
+		{"/employees/1", "application/merge-patch+json", `{"lastName": "Dude"`, http.StatusBadRequest},
+		{"/employees/1", "application/merge-patch+json", `["lastName"]`, http.StatusBadRequest},
+		{"/employees/99", "application/merge-patch+json", `{"lastName": "Dude"}`, http.StatusNotFound},
+		{"/events/1", "application/merge-patch+json", `{"date": "05.08.2022"}`, http.StatusUnprocessableEntity},
+		{"/events/1", "application/merge-patch+json; charset=utf-8", `{"name": "Summer Party", "id": 7}`, http.StatusOK},
+	} {
+		req, _ := http.NewRequest("PATCH", tc.url, strings.NewReader(tc.body))
+		req.Header.Set("Content-Type", tc.contentType)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(tc.expectedCode, w.Code, "http Code doesn't match for %s %s", tc.url, tc.body)
+	}
+
+	//the id is taken from the path, not from the patch
+	event, err := store.GetEvent(context.Background(), 1)
+	assert.NoError(err)
+	assert.Equal("Summer Party", event.Name)
+}
+
 func TestDeleteEmployee(t *testing.T) {
 	//Init mock db
 	db, mock := newMock()
 
-	h := handlers.New(db)
+	store := postgres.New(db)
+	h := handlers.New(store, store, store)
 	//Init router
 	router := gin.Default()
 	router.DELETE("/employees/:id", h.DeleteEmployee) //delete specified employee
@@ -182,14 +411,19 @@ func TestDeleteEmployee(t *testing.T) {
 	req, _ := http.NewRequest("DELETE", "/employees/3", nil)
 
 	//employee to test
-	emp := models.Employee{ID: 3, FirstName: "Joe", LastName: "Jones", BirthDay: "1997-09-12", Gender: "m"}
+	emp := models.Employee{ID: 3, FirstName: "Joe", LastName: "Jones", BirthDay: date("1997-09-12"), Gender: "m"}
 
-	//row for select query
-	rows := sqlmock.NewRows([]string{"id", "first_name", "last_name", "birthday", "gender"}).AddRow(
-		emp.ID, emp.FirstName, emp.LastName, emp.BirthDay, emp.Gender)
+	//row of the employee marked as deleted
+	rows := sqlmock.NewRows(employeeColumns).AddRow(
+		emp.ID, emp.FirstName, emp.LastName, emp.BirthDay.String(), emp.Gender, registeredAt)
 
+	//employees are only marked as deleted
+	mock.ExpectBegin()
 	mock.ExpectQuery(regexp.QuoteMeta(
-		"DELETE FROM employees WHERE id = $1 RETURNING *")).WithArgs(strconv.Itoa(emp.ID)).WillReturnRows(rows)
+		"UPDATE employees SET deleted_at = now() WHERE id = $1 AND deleted_at IS NULL RETURNING *")).WithArgs(emp.ID).WillReturnRows(rows)
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO audit_log")).WithArgs("system", "delete", "employee", "3",
+		`{}`, `{"deletedAt":"2022-07-01T09:00:00Z"}`, "").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
 
 	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
@@ -199,7 +433,8 @@ func TestDeleteEmployee(t *testing.T) {
 			"firstName": "Joe",
 			"lastName": "Jones",
 			"birthDay": "1997-09-12",
-			"gender": "m"
+			"gender": "m",
+			"deletedAt": "2022-07-01T09:00:00Z"
 		}`
 	assert := assert.New(t)
 	assert.Equal(http.StatusOK, w.Code, "http Code doesn't match")
@@ -216,7 +451,9 @@ func TestGetEvents(t *testing.T) {
 	//Init mock db
 	db, mock := newMock()
 
-	h := handlers.New(db)
+	store := postgres.New(db)
+	h := handlers.New(store, store, store)
+	h.Now = func() time.Time { return today }
 	//Init router
 	router := gin.Default()
 	router.GET("/events", h.GetEvents) //get all upcoming events
@@ -229,7 +466,7 @@ func TestGetEvents(t *testing.T) {
 		1, "Costume Party", "2022-08-01").AddRow(2, "Escape Room", "2022-08-02")
 
 	mock.ExpectQuery(regexp.QuoteMeta(
-		"SELECT * FROM events ORDER BY id")).WillReturnRows(rows)
+		"SELECT * FROM events WHERE date >= $1 ORDER BY date, id")).WithArgs("2022-07-15", 51).WillReturnRows(rows)
 
 	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
@@ -260,7 +497,8 @@ func TestGetEvent(t *testing.T) {
 	//Init mock db
 	db, mock := newMock()
 
-	h := handlers.New(db)
+	store := postgres.New(db)
+	h := handlers.New(store, store, store)
 	//Init router
 	router := gin.Default()
 	router.GET("/events/:id", h.GetEvent) //get specific event
@@ -269,14 +507,14 @@ func TestGetEvent(t *testing.T) {
 	req, _ := http.NewRequest("GET", "/events/1", nil)
 
 	// Event to test
-	event := models.Event{ID: 1, Name: "Costume Party", Date: "2022-08-01"}
+	event := models.Event{ID: 1, Name: "Costume Party", Date: date("2022-08-01")}
 
 	//row for select query
 	rows := sqlmock.NewRows([]string{"id", "name", "date"}).AddRow(
-		event.ID, event.Name, event.Date)
+		event.ID, event.Name, event.Date.String())
 
 	mock.ExpectQuery(regexp.QuoteMeta(
-		"SELECT * FROM events WHERE id = $1")).WithArgs(strconv.Itoa(event.ID)).WillReturnRows(rows)
+		"SELECT * FROM events WHERE id = $1")).WithArgs(event.ID).WillReturnRows(rows)
 
 	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
@@ -301,7 +539,8 @@ func TestGetEmployeesForEvent(t *testing.T) {
 	//Init mock db
 	db, mock := newMock()
 
-	h := handlers.New(db)
+	store := postgres.New(db)
+	h := handlers.New(store, store, store)
 	//Init router
 	router := gin.Default()
 	router.GET("/events/:id/employees", h.GetEmployeesForEvent)
@@ -310,16 +549,18 @@ func TestGetEmployeesForEvent(t *testing.T) {
 	req, _ := http.NewRequest("GET", "/events/1/employees", nil)
 
 	// Event to test
-	event := models.Event{ID: 1, Name: "Costume Party", Date: "2022-08-01"}
+	event := models.Event{ID: 1, Name: "Costume Party", Date: date("2022-08-01")}
 
-	query := `SELECT id, first_name, last_name, birthday, gender FROM employees JOIN attendances 
-		ON attendances.employee_id = employees.id WHERE attendances.event_id = $1 ORDER BY id`
+	query := `SELECT id, first_name, last_name, birthday, gender, deleted_at, accommodation, registered_at, status FROM employees JOIN attendances 
+		ON attendances.employee_id = employees.id WHERE attendances.event_id = $1 AND employees.deleted_at IS NULL ORDER BY id LIMIT $2`
 
 	//row for select query
-	rows := sqlmock.NewRows([]string{"id", "first_name", "last_name", "birthday", "gender"}).AddRow(
-		1, "Son", "Nong", "1999-05-19", "m").AddRow(2, "Max", "Mustermann", "1998-04-18", "m").AddRow(3, "Joe", "Jones", "1997-09-12", "m")
+	rows := sqlmock.NewRows(attendeeColumns).AddRow(
+		1, "Son", "Nong", "1999-05-19", "m", nil, true, registeredAt, "registered").AddRow(
+		2, "Max", "Mustermann", "1998-04-18", "m", nil, true, registeredAt, "registered").AddRow(
+		3, "Joe", "Jones", "1997-09-12", "m", nil, false, registeredAt, "waitlisted")
 
-	mock.ExpectQuery(regexp.QuoteMeta(query)).WithArgs(strconv.Itoa(event.ID)).WillReturnRows(rows)
+	mock.ExpectQuery(regexp.QuoteMeta(query)).WithArgs(event.ID, 51).WillReturnRows(rows)
 
 	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
@@ -330,21 +571,30 @@ func TestGetEmployeesForEvent(t *testing.T) {
 			"firstName": "Son",
 			"lastName": "Nong",
 			"birthDay": "1999-05-19",
-			"gender": "m"
+			"gender": "m",
+			"accommodation": true,
+			"registeredAt": "2022-07-01T09:00:00Z",
+			"status": "registered"
 		},
 		{
 			"id": 2,
 			"firstName": "Max",
 			"lastName": "Mustermann",
 			"birthDay": "1998-04-18",
-			"gender": "m"
+			"gender": "m",
+			"accommodation": true,
+			"registeredAt": "2022-07-01T09:00:00Z",
+			"status": "registered"
 		},
 		{
 			"id": 3,
 			"firstName": "Joe",
 			"lastName": "Jones",
 			"birthDay": "1997-09-12",
-			"gender": "m"
+			"gender": "m",
+			"accommodation": false,
+			"registeredAt": "2022-07-01T09:00:00Z",
+			"status": "waitlisted"
 		}
 	]`
 
@@ -363,7 +613,8 @@ func TestGetEmployeesForEventAcc(t *testing.T) {
 	//Init mock db
 	db, mock := newMock()
 
-	h := handlers.New(db)
+	store := postgres.New(db)
+	h := handlers.New(store, store, store)
 	//Init router
 	router := gin.Default()
 	router.GET("/events/:id/employees", h.GetEmployeesForEvent)
@@ -375,16 +626,17 @@ func TestGetEmployeesForEventAcc(t *testing.T) {
 	req.URL.RawQuery = q.Encode()
 
 	// Event to test
-	event := models.Event{ID: 1, Name: "Costume Party", Date: "2022-08-01"}
+	event := models.Event{ID: 1, Name: "Costume Party", Date: date("2022-08-01")}
 
-	query := `SELECT id, first_name, last_name, birthday, gender FROM employees JOIN attendances 
-		ON attendances.employee_id = employees.id WHERE attendances.event_id = $1 AND accommodation = true ORDER BY id`
+	query := `SELECT id, first_name, last_name, birthday, gender, deleted_at, accommodation, registered_at, status FROM employees JOIN attendances 
+		ON attendances.employee_id = employees.id WHERE attendances.event_id = $1 AND employees.deleted_at IS NULL AND accommodation = true ORDER BY id LIMIT $2`
 
 	//row for select query
-	rows := sqlmock.NewRows([]string{"id", "first_name", "last_name", "birthday", "gender"}).AddRow(
-		1, "Son", "Nong", "1999-05-19", "m").AddRow(2, "Max", "Mustermann", "1998-04-18", "m")
+	rows := sqlmock.NewRows(attendeeColumns).AddRow(
+		1, "Son", "Nong", "1999-05-19", "m", nil, true, registeredAt, "registered").AddRow(
+		2, "Max", "Mustermann", "1998-04-18", "m", nil, true, registeredAt, "registered")
 
-	mock.ExpectQuery(regexp.QuoteMeta(query)).WithArgs(strconv.Itoa(event.ID)).WillReturnRows(rows)
+	mock.ExpectQuery(regexp.QuoteMeta(query)).WithArgs(event.ID, 51).WillReturnRows(rows)
 
 	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
@@ -395,14 +647,20 @@ func TestGetEmployeesForEventAcc(t *testing.T) {
 			"firstName": "Son",
 			"lastName": "Nong",
 			"birthDay": "1999-05-19",
-			"gender": "m"
+			"gender": "m",
+			"accommodation": true,
+			"registeredAt": "2022-07-01T09:00:00Z",
+			"status": "registered"
 		},
 		{
 			"id": 2,
 			"firstName": "Max",
 			"lastName": "Mustermann",
 			"birthDay": "1998-04-18",
-			"gender": "m"
+			"gender": "m",
+			"accommodation": true,
+			"registeredAt": "2022-07-01T09:00:00Z",
+			"status": "registered"
 		}
 	]`
 
@@ -421,7 +679,8 @@ func TestGetEmployeesForEventNoAcc(t *testing.T) {
 	//Init mock db
 	db, mock := newMock()
 
-	h := handlers.New(db)
+	store := postgres.New(db)
+	h := handlers.New(store, store, store)
 	//Init router
 	router := gin.Default()
 	router.GET("/events/:id/employees", h.GetEmployeesForEvent)
@@ -433,16 +692,16 @@ func TestGetEmployeesForEventNoAcc(t *testing.T) {
 	req.URL.RawQuery = q.Encode()
 
 	// Event to test
-	event := models.Event{ID: 1, Name: "Costume Party", Date: "2022-08-01"}
+	event := models.Event{ID: 1, Name: "Costume Party", Date: date("2022-08-01")}
 
-	query := `SELECT id, first_name, last_name, birthday, gender FROM employees JOIN attendances 
-		ON attendances.employee_id = employees.id WHERE attendances.event_id = $1 AND accommodation = false ORDER BY id`
+	query := `SELECT id, first_name, last_name, birthday, gender, deleted_at, accommodation, registered_at, status FROM employees JOIN attendances 
+		ON attendances.employee_id = employees.id WHERE attendances.event_id = $1 AND employees.deleted_at IS NULL AND accommodation = false ORDER BY id LIMIT $2`
 
 	//row for select query
-	rows := sqlmock.NewRows([]string{"id", "first_name", "last_name", "birthday", "gender"}).AddRow(
-		3, "Joe", "Jones", "1997-09-12", "m")
+	rows := sqlmock.NewRows(attendeeColumns).AddRow(
+		3, "Joe", "Jones", "1997-09-12", "m", nil, false, registeredAt, "waitlisted")
 
-	mock.ExpectQuery(regexp.QuoteMeta(query)).WithArgs(strconv.Itoa(event.ID)).WillReturnRows(rows)
+	mock.ExpectQuery(regexp.QuoteMeta(query)).WithArgs(event.ID, 51).WillReturnRows(rows)
 
 	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
@@ -453,7 +712,10 @@ func TestGetEmployeesForEventNoAcc(t *testing.T) {
 			"firstName": "Joe",
 			"lastName": "Jones",
 			"birthDay": "1997-09-12",
-			"gender": "m"
+			"gender": "m",
+			"accommodation": false,
+			"registeredAt": "2022-07-01T09:00:00Z",
+			"status": "waitlisted"
 		}
 	]`
 
@@ -466,3 +728,1902 @@ func TestGetEmployeesForEventNoAcc(t *testing.T) {
 		t.Errorf("there were unfulfilled expectations: %s", err)
 	}
 }
+
+func TestPostEvent(t *testing.T) {
+	//Init mock db
+	db, mock := newMock()
+
+	store := postgres.New(db)
+	h := handlers.New(store, store, store)
+	//Init router
+	router := gin.Default()
+	router.POST("/events", h.PostEvent) //registers new event
+
+	//http request
+	req, _ := http.NewRequest("POST", "/events", strings.NewReader(
+		`{"name": "Hackathon", "date": "2022-09-15"}`))
+
+	//mock db should return this on specified query
+	rows := sqlmock.NewRows([]string{"id"}).AddRow(3)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(
+		`INSERT INTO events (name, date) VALUES ($1, $2) RETURNING id`)).WithArgs(
+		"Hackathon", "2022-09-15").WillReturnRows(rows)
+	expectAudit(mock, "create", "event", "3")
+	mock.ExpectCommit()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	expectedResp := `{
+			"id": 3,
+			"name": "Hackathon",
+			"date": "2022-09-15"
+		}`
+	assert := assert.New(t)
+	assert.Equal(http.StatusCreated, w.Code, "expected http Code 201")
+	assert.JSONEq(expectedResp, w.Body.String(), "Response body doesn't match")
+
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// Events without a name or with an invalid date are rejected before reaching the db
+func TestPostEventInvalid(t *testing.T) {
+	//Init mock db
+	db, mock := newMock()
+
+	store := postgres.New(db)
+	h := handlers.New(store, store, store)
+	//Init router
+	router := gin.Default()
+	router.POST("/events", h.PostEvent) //registers new event
+
+	assert := assert.New(t)
+	for _, body := range []string{
+		`{"name": "", "date": "2022-09-15"}`,
+		`{"name": "Hackathon", "date": "banana"}`,
+	} {
+		req, _ := http.NewRequest("POST", "/events", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(http.StatusUnprocessableEntity, w.Code, "expected http Code 422 for %s", body)
+	}
+
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPutEvent(t *testing.T) {
+	//Init mock db
+	db, mock := newMock()
+
+	store := postgres.New(db)
+	h := handlers.New(store, store, store)
+	//Init router
+	router := gin.Default()
+	router.PUT("/events/:id", h.PutEvent) //replace event info
+
+	//http request
+	req, _ := http.NewRequest("PUT", "/events/1", strings.NewReader(
+		`{"name": "Costume Party", "date": "2022-08-05"}`))
+
+	//row after specified event is updated in db
+	updRows := sqlmock.NewRows([]string{"id", "name", "date"}).AddRow(1, "Costume Party", "2022-08-05")
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(
+		"SELECT * FROM events WHERE id = $1 FOR UPDATE")).WithArgs(1).WillReturnRows(
+		sqlmock.NewRows([]string{"id", "name", "date"}).AddRow(1, "Costume Party", "2022-08-01"))
+	mock.ExpectQuery(regexp.QuoteMeta(
+		"UPDATE events SET name = $1, date = $2 WHERE id = $3 RETURNING *")).WithArgs(
+		"Costume Party", "2022-08-05", 1).WillReturnRows(updRows)
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO audit_log")).WithArgs("system", "update", "event", "1",
+		`{"date":"2022-08-01"}`, `{"date":"2022-08-05"}`, "").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	expectedResp := `{
+			"id": 1,
+			"name": "Costume Party",
+			"date": "2022-08-05"
+		}`
+	assert := assert.New(t)
+	assert.Equal(http.StatusOK, w.Code, "http Code doesn't match")
+	assert.JSONEq(expectedResp, w.Body.String(), "Response body doesn't match")
+
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPatchEvent(t *testing.T) {
+	//Init mock db
+	db, mock := newMock()
+
+	store := postgres.New(db)
+	h := handlers.New(store, store, store)
+	//Init router
+	router := gin.Default()
+	router.PATCH("/events/:id", h.PatchEvent) //update event info with a merge patch
+
+	//http request, the name keeps its value
+	req, _ := http.NewRequest("PATCH", "/events/1", strings.NewReader(
+		`{"date": "2022-08-05"}`))
+	req.Header.Set("Content-Type", "application/merge-patch+json")
+
+	//the patch is applied to the row locked in the transaction
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(
+		"SELECT * FROM events WHERE id = $1 FOR UPDATE")).WithArgs(1).WillReturnRows(
+		sqlmock.NewRows([]string{"id", "name", "date"}).AddRow(1, "Costume Party", "2022-08-01"))
+	mock.ExpectQuery(regexp.QuoteMeta(
+		"UPDATE events SET name = $1, date = $2 WHERE id = $3 RETURNING *")).WithArgs(
+		"Costume Party", "2022-08-05", 1).WillReturnRows(
+		sqlmock.NewRows([]string{"id", "name", "date"}).AddRow(1, "Costume Party", "2022-08-05"))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO audit_log")).WithArgs("system", "update", "event", "1",
+		`{"date":"2022-08-01"}`, `{"date":"2022-08-05"}`, "").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	expectedResp := `{
+			"id": 1,
+			"name": "Costume Party",
+			"date": "2022-08-05"
+		}`
+	assert := assert.New(t)
+	assert.Equal(http.StatusOK, w.Code, "http Code doesn't match")
+	assert.JSONEq(expectedResp, w.Body.String(), "Response body doesn't match")
+
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// Deleting an event with registered employees is refused without cascade
+func TestDeleteEventConflict(t *testing.T) {
+	//Init mock db
+	db, mock := newMock()
+
+	store := postgres.New(db)
+	h := handlers.New(store, store, store)
+	//Init router
+	router := gin.Default()
+	router.DELETE("/events/:id", h.DeleteEvent) //delete specified event
+
+	//http request
+	req, _ := http.NewRequest("DELETE", "/events/1", nil)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(
+		"SELECT id FROM events WHERE id = $1 FOR UPDATE")).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	//attendances of deleted employees aren't counted
+	mock.ExpectQuery(regexp.QuoteMeta(
+		"SELECT COUNT(*) FROM attendances JOIN employees ON employees.id = employee_id")).WithArgs(1).WillReturnRows(
+		sqlmock.NewRows([]string{"count"}).AddRow(2))
+	mock.ExpectRollback()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert := assert.New(t)
+	assert.Equal(http.StatusConflict, w.Code, "http Code doesn't match")
+
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// Deleting an event with cascade=true removes its attendances as well
+func TestDeleteEventCascade(t *testing.T) {
+	//Init mock db
+	db, mock := newMock()
+
+	store := postgres.New(db)
+	h := handlers.New(store, store, store)
+	//Init router
+	router := gin.Default()
+	router.DELETE("/events/:id", h.DeleteEvent) //delete specified event
+
+	//http request
+	req, _ := http.NewRequest("DELETE", "/events/1?cascade=true", nil)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(
+		"SELECT id FROM events WHERE id = $1 FOR UPDATE")).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery(regexp.QuoteMeta(
+		"DELETE FROM attendances WHERE event_id = $1 RETURNING")).WithArgs(1).WillReturnRows(
+		sqlmock.NewRows(attendanceColumns).AddRow(1, 2, true, registeredAt, "registered").AddRow(1, 4, false, registeredAt, "registered"))
+	expectAudit(mock, "delete", "attendance", "1/2")
+	expectAudit(mock, "delete", "attendance", "1/4")
+	mock.ExpectQuery(regexp.QuoteMeta(
+		"DELETE FROM events WHERE id = $1 RETURNING *")).WithArgs(1).WillReturnRows(
+		sqlmock.NewRows([]string{"id", "name", "date"}).AddRow(1, "Costume Party", "2022-08-01"))
+	expectAudit(mock, "delete", "event", "1")
+	mock.ExpectCommit()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	expectedResp := `{
+			"id": 1,
+			"name": "Costume Party",
+			"date": "2022-08-01"
+		}`
+	assert := assert.New(t)
+	assert.Equal(http.StatusOK, w.Code, "http Code doesn't match")
+	assert.JSONEq(expectedResp, w.Body.String(), "Response body doesn't match")
+
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// Attendances of deleted employees don't keep an event from being deleted, they are deleted with it
+func TestDeleteEventDeletedAttendees(t *testing.T) {
+	store := memory.New()
+	assert := assert.New(t)
+	assert.NoError(store.Seed(context.Background()))
+	h := handlers.New(store, store, store)
+	//Init router
+	router := gin.Default()
+	router.DELETE("/employees/:id", h.DeleteEmployee)       //delete specified employee
+	router.DELETE("/events/:id", h.DeleteEvent)             //delete specified event
+	router.GET("/audit", handlers.NewAudit(store).GetAudit) //changes to employees, events and attendances
+
+	//employees 2 and 4 attend event 2
+	for _, tc := range []struct {
+		method, url  string
+		expectedCode int
+		expectedResp string
+	}{
+		{"DELETE", "/employees/2", http.StatusOK, ""},
+		{"DELETE", "/events/2", http.StatusConflict, `{"code": "conflict",
+			"message": "event has 1 registered employees, use cascade=true to delete them as well"}`},
+		{"DELETE", "/employees/4", http.StatusOK, ""},
+		{"DELETE", "/events/2", http.StatusOK, ""},
+	} {
+		req, _ := http.NewRequest(tc.method, tc.url, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(tc.expectedCode, w.Code, "http Code doesn't match for %s %s", tc.method, tc.url)
+		if tc.expectedResp != "" {
+			assert.JSONEq(tc.expectedResp, w.Body.String(), "Response body doesn't match for %s %s", tc.method, tc.url)
+		}
+	}
+
+	req, _ := http.NewRequest("GET", "/audit?entity=attendance&id=2/4", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	var entries []models.AuditEntry
+	assert.NoError(json.Unmarshal(w.Body.Bytes(), &entries))
+	var actions []string
+	for _, entry := range entries {
+		actions = append(actions, entry.Action)
+	}
+	assert.Equal([]string{"create", "delete"}, actions)
+}
+
+// Register an employee for an event
+func TestPostAttendance(t *testing.T) {
+	//Init mock db
+	db, mock := newMock()
+
+	store := postgres.New(db)
+	h := handlers.New(store, store, store)
+	//Init router
+	router := gin.Default()
+	router.POST("/events/:id/employees", h.PostAttendance)
+
+	//http request
+	req, _ := http.NewRequest("POST", "/events/1/employees", strings.NewReader(
+		`{"employeeId": 3, "accommodation": true}`))
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(
+		`SELECT EXISTS(SELECT 1 FROM events WHERE id = $1), EXISTS(SELECT 1 FROM employees WHERE id = $2 AND deleted_at IS NULL)`)).WithArgs(
+		1, 3).WillReturnRows(sqlmock.NewRows([]string{"exists", "exists"}).AddRow(true, true))
+	mock.ExpectQuery(regexp.QuoteMeta(
+		`INSERT INTO attendances (event_id, employee_id, accommodation) VALUES ($1, $2, $3)`)).WithArgs(
+		1, 3, true).WillReturnRows(sqlmock.NewRows(attendanceColumns).AddRow(1, 3, true, registeredAt, "registered"))
+	expectAudit(mock, "create", "attendance", "1/3")
+	mock.ExpectCommit()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	expectedResp := `{
+			"eventId": 1,
+			"employeeId": 3,
+			"accommodation": true,
+			"registeredAt": "2022-07-01T09:00:00Z",
+			"status": "registered"
+		}`
+	assert := assert.New(t)
+	assert.Equal(http.StatusCreated, w.Code, "expected http Code 201")
+	assert.JSONEq(expectedResp, w.Body.String(), "Response body doesn't match")
+
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// Registering an employee twice for the same event returns 409
+func TestPostAttendanceDuplicate(t *testing.T) {
+	//Init mock db
+	db, mock := newMock()
+
+	store := postgres.New(db)
+	h := handlers.New(store, store, store)
+	//Init router
+	router := gin.Default()
+	router.POST("/events/:id/employees", h.PostAttendance)
+
+	//http request
+	req, _ := http.NewRequest("POST", "/events/1/employees", strings.NewReader(
+		`{"employeeId": 3, "accommodation": true}`))
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(
+		`SELECT EXISTS(SELECT 1 FROM events WHERE id = $1), EXISTS(SELECT 1 FROM employees WHERE id = $2 AND deleted_at IS NULL)`)).WithArgs(
+		1, 3).WillReturnRows(sqlmock.NewRows([]string{"exists", "exists"}).AddRow(true, true))
+	mock.ExpectQuery(regexp.QuoteMeta(
+		`INSERT INTO attendances (event_id, employee_id, accommodation) VALUES ($1, $2, $3)`)).WithArgs(
+		1, 3, true).WillReturnError(&pq.Error{Code: "23505"})
+	mock.ExpectRollback()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert := assert.New(t)
+	assert.Equal(http.StatusConflict, w.Code, "expected http Code 409")
+
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// Registering an unknown employee returns 404
+func TestPostAttendanceUnknownEmployee(t *testing.T) {
+	//Init mock db
+	db, mock := newMock()
+
+	store := postgres.New(db)
+	h := handlers.New(store, store, store)
+	//Init router
+	router := gin.Default()
+	router.POST("/events/:id/employees", h.PostAttendance)
+
+	//http request
+	req, _ := http.NewRequest("POST", "/events/1/employees", strings.NewReader(
+		`{"employeeId": 42}`))
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(
+		`SELECT EXISTS(SELECT 1 FROM events WHERE id = $1), EXISTS(SELECT 1 FROM employees WHERE id = $2 AND deleted_at IS NULL)`)).WithArgs(
+		1, 42).WillReturnRows(sqlmock.NewRows([]string{"exists", "exists"}).AddRow(true, false))
+	mock.ExpectRollback()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert := assert.New(t)
+	assert.Equal(http.StatusNotFound, w.Code, "expected http Code 404")
+
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// Update the accommodation flag of a registered employee
+func TestPatchAttendance(t *testing.T) {
+	//Init mock db
+	db, mock := newMock()
+
+	store := postgres.New(db)
+	h := handlers.New(store, store, store)
+	//Init router
+	router := gin.Default()
+	router.PATCH("/events/:id/employees/:employeeId", h.PatchAttendance)
+
+	//http request
+	req, _ := http.NewRequest("PATCH", "/events/1/employees/3", strings.NewReader(
+		`{"accommodation": false}`))
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(
+		`FROM attendances WHERE event_id = $1 AND employee_id = $2 FOR UPDATE`)).WithArgs(
+		1, 3).WillReturnRows(sqlmock.NewRows(attendanceColumns).AddRow(1, 3, true, registeredAt, "registered"))
+	mock.ExpectQuery(regexp.QuoteMeta(
+		`UPDATE attendances SET accommodation = $1 WHERE event_id = $2 AND employee_id = $3`)).WithArgs(
+		false, 1, 3).WillReturnRows(sqlmock.NewRows(attendanceColumns).AddRow(1, 3, false, registeredAt, "registered"))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO audit_log")).WithArgs("system", "update", "attendance", "1/3",
+		`{"accommodation":true}`, `{"accommodation":false}`, "").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	expectedResp := `{
+			"eventId": 1,
+			"employeeId": 3,
+			"accommodation": false,
+			"registeredAt": "2022-07-01T09:00:00Z",
+			"status": "registered"
+		}`
+	assert := assert.New(t)
+	assert.Equal(http.StatusOK, w.Code, "http Code doesn't match")
+	assert.JSONEq(expectedResp, w.Body.String(), "Response body doesn't match")
+
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// Requests without a body toggle the accommodation flag
+func TestPatchAttendanceToggle(t *testing.T) {
+	//Init mock db
+	db, mock := newMock()
+
+	store := postgres.New(db)
+	h := handlers.New(store, store, store)
+	//Init router
+	router := gin.Default()
+	router.PATCH("/events/:id/employees/:employeeId", h.PatchAttendance)
+
+	//http request, the body of chunked requests has no content length
+	req, _ := http.NewRequest("PATCH", "/events/1/employees/3", strings.NewReader("\n"))
+	req.ContentLength = -1
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(
+		`FROM attendances WHERE event_id = $1 AND employee_id = $2 FOR UPDATE`)).WithArgs(
+		1, 3).WillReturnRows(sqlmock.NewRows(attendanceColumns).AddRow(1, 3, true, registeredAt, "registered"))
+	mock.ExpectQuery(regexp.QuoteMeta(
+		`UPDATE attendances SET accommodation = NOT accommodation WHERE event_id = $1 AND employee_id = $2`)).WithArgs(
+		1, 3).WillReturnRows(sqlmock.NewRows(attendanceColumns).AddRow(1, 3, false, registeredAt, "registered"))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO audit_log")).WithArgs("system", "update", "attendance", "1/3",
+		`{"accommodation":true}`, `{"accommodation":false}`, "").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert := assert.New(t)
+	assert.Equal(http.StatusOK, w.Code, "http Code doesn't match")
+	assert.Contains(w.Body.String(), `"accommodation": false`, "Response body doesn't match")
+
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// Bodies without the accommodation flag are rejected instead of toggling it
+func TestPatchAttendanceInvalid(t *testing.T) {
+	//Init mock db
+	db, mock := newMock()
+
+	store := postgres.New(db)
+	h := handlers.New(store, store, store)
+	//Init router
+	router := gin.Default()
+	router.PATCH("/events/:id/employees/:employeeId", h.PatchAttendance)
+
+	assert := assert.New(t)
+	for _, body := range []string{
+		`{}`,
+		`{"accommodation": null}`,
+		`{"accomodation": true}`,
+	} {
+		req, _ := http.NewRequest("PATCH", "/events/1/employees/3", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(http.StatusUnprocessableEntity, w.Code, "expected http Code 422 for %s", body)
+		assert.Contains(w.Body.String(), "invalid fields: accommodation", "expected an error for accommodation for %s", body)
+	}
+
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// Withdraw an employee from an event
+func TestDeleteAttendance(t *testing.T) {
+	//Init mock db
+	db, mock := newMock()
+
+	store := postgres.New(db)
+	h := handlers.New(store, store, store)
+	//Init router
+	router := gin.Default()
+	router.DELETE("/events/:id/employees/:employeeId", h.DeleteAttendance)
+
+	//http request
+	req, _ := http.NewRequest("DELETE", "/events/1/employees/3", nil)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(
+		`DELETE FROM attendances WHERE event_id = $1 AND employee_id = $2`)).WithArgs(
+		1, 3).WillReturnRows(sqlmock.NewRows(attendanceColumns).AddRow(1, 3, true, registeredAt, "registered"))
+	expectAudit(mock, "delete", "attendance", "1/3")
+	mock.ExpectCommit()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	expectedResp := `{
+			"eventId": 1,
+			"employeeId": 3,
+			"accommodation": true,
+			"registeredAt": "2022-07-01T09:00:00Z",
+			"status": "registered"
+		}`
+	assert := assert.New(t)
+	assert.Equal(http.StatusOK, w.Code, "http Code doesn't match")
+	assert.JSONEq(expectedResp, w.Body.String(), "Response body doesn't match")
+
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// Return the upcoming events an employee is registered for
+func TestGetEventsForEmployeeUpcoming(t *testing.T) {
+	//Init mock db
+	db, mock := newMock()
+
+	store := postgres.New(db)
+	h := handlers.New(store, store, store)
+	h.Now = func() time.Time { return today }
+	//Init router
+	router := gin.Default()
+	router.GET("/employees/:id/events", h.GetEventsForEmployee)
+
+	//http request
+	req, _ := http.NewRequest("GET", "/employees/3/events?upcoming=true", nil)
+
+	query := `SELECT id, name, date, accommodation, registered_at, status FROM events JOIN attendances
+		ON attendances.event_id = events.id WHERE attendances.employee_id = $1 AND date >= $2 ORDER BY date, id`
+
+	//row for select query
+	rows := sqlmock.NewRows([]string{"id", "name", "date", "accommodation", "registered_at", "status"}).AddRow(
+		2, "Escape Room", "2022-08-02", true, registeredAt, "registered")
+
+	mock.ExpectQuery(regexp.QuoteMeta(query)).WithArgs(3, "2022-07-15").WillReturnRows(rows)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	expectedResp := `[
+		{
+			"id": 2,
+			"name": "Escape Room",
+			"date": "2022-08-02",
+			"accommodation": true,
+			"registeredAt": "2022-07-01T09:00:00Z",
+			"status": "registered"
+		}
+	]`
+
+	assert := assert.New(t)
+	assert.Equal(http.StatusOK, w.Code, "http Code doesn't match")
+	assert.JSONEq(expectedResp, w.Body.String(), "Response body doesn't match")
+
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// Unknown employees are reported as not found instead of an empty list of events
+func TestGetEventsForEmployeeNotFound(t *testing.T) {
+	assert := assert.New(t)
+	for _, tc := range []struct {
+		exists       bool
+		expectedCode int
+		expectedResp string
+	}{
+		{false, http.StatusNotFound, `{"code": "not_found", "message": "employee 9 not found"}`},
+		{true, http.StatusOK, `null`},
+	} {
+		//Init mock db
+		db, mock := newMock()
+
+		store := postgres.New(db)
+		h := handlers.New(store, store, store)
+		//Init router
+		router := gin.Default()
+		router.GET("/employees/:id/events", h.GetEventsForEmployee)
+
+		//http request
+		req, _ := http.NewRequest("GET", "/employees/9/events", nil)
+
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT id, name, date, accommodation, registered_at, status FROM events")).WithArgs(9).WillReturnRows(
+			sqlmock.NewRows([]string{"id", "name", "date", "accommodation", "registered_at", "status"}))
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT EXISTS(SELECT 1 FROM employees WHERE id = $1)")).WithArgs(9).WillReturnRows(
+			sqlmock.NewRows([]string{"exists"}).AddRow(tc.exists))
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(tc.expectedCode, w.Code, "http Code doesn't match if the employee exists: %t", tc.exists)
+		assert.JSONEq(tc.expectedResp, w.Body.String(), "Response body doesn't match if the employee exists: %t", tc.exists)
+
+		// we make sure that all expectations were met
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	}
+}
+
+// Return the events in a date range including past events
+func TestGetEventsRange(t *testing.T) {
+	//Init mock db
+	db, mock := newMock()
+
+	store := postgres.New(db)
+	h := handlers.New(store, store, store)
+	h.Now = func() time.Time { return today }
+	//Init router
+	router := gin.Default()
+	router.GET("/events", h.GetEvents) //get all upcoming events
+
+	//http request
+	req, _ := http.NewRequest("GET", "/events?include_past=true&from=2022-07-01&to=2022-07-31", nil)
+
+	//row for select query
+	rows := sqlmock.NewRows([]string{"id", "name", "date"}).AddRow(3, "Summer BBQ", "2022-07-08")
+
+	mock.ExpectQuery(regexp.QuoteMeta(
+		"SELECT * FROM events WHERE date >= $1 AND date <= $2 ORDER BY date, id")).WithArgs(
+		"2022-07-01", "2022-07-31", 51).WillReturnRows(rows)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	expectedResp := `[
+		{
+			"id": 3,
+			"name": "Summer BBQ",
+			"date": "2022-07-08"
+		}
+	]`
+	assert := assert.New(t)
+	assert.Equal(http.StatusOK, w.Code, "http Code doesn't match")
+	assert.JSONEq(expectedResp, w.Body.String(), "Response body doesn't match")
+
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// Return the second page of employees with the total count and a link to the next page
+func TestGetEmployeesPage(t *testing.T) {
+	//Init mock db
+	db, mock := newMock()
+
+	store := postgres.New(db)
+	h := handlers.New(store, store, store)
+	//Init router
+	router := gin.Default()
+	router.GET("/employees", h.GetEmployees) //get all employees
+
+	//http request
+	req, _ := http.NewRequest("GET", "/employees?limit=1&cursor=1&include_total=true", nil)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*) FROM employees")).WillReturnRows(
+		sqlmock.NewRows([]string{"count"}).AddRow(3))
+
+	//limit + 1 rows are fetched to find out if there is a next page
+	rows := sqlmock.NewRows(employeeColumns).AddRow(
+		2, "Max", "Mustermann", "1998-04-18", "m", nil).AddRow(3, "Joe", "Jones", "1997-09-12", "m", nil)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM employees WHERE deleted_at IS NULL AND id > $1 ORDER BY id LIMIT $2")).WithArgs(
+		1, 2).WillReturnRows(rows)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	expectedResp := `[
+		{
+			"id": 2,
+			"firstName": "Max",
+			"lastName": "Mustermann",
+			"birthDay": "1998-04-18",
+			"gender": "m"
+		}
+	]`
+	assert := assert.New(t)
+	assert.Equal(http.StatusOK, w.Code, "expected http Code 200")
+	assert.JSONEq(expectedResp, w.Body.String(), "Response body doesn't match")
+	assert.Equal("3", w.Header().Get("X-Total-Count"))
+	assert.Equal("2", w.Header().Get("X-Next-Cursor"))
+	assert.Equal(`</employees?cursor=2&include_total=true&limit=1>; rel="next"`, w.Header().Get("Link"))
+
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// Return the employees matching the filters in the requested order
+func TestGetEmployeesFilterSort(t *testing.T) {
+	//Init mock db
+	db, mock := newMock()
+
+	store := postgres.New(db)
+	h := handlers.New(store, store, store)
+	//Init router
+	router := gin.Default()
+	router.GET("/employees", h.GetEmployees) //get all employees
+
+	//http request
+	req, _ := http.NewRequest("GET", "/employees?name=mu&gender=m&birthday_from=1990-01-01&sort=lastName,-birthDay", nil)
+
+	query := `SELECT * FROM employees WHERE deleted_at IS NULL AND (first_name ILIKE $1 OR last_name ILIKE $1) AND gender = $2 AND birthday >= $3
+		ORDER BY last_name, birthday DESC, id LIMIT $4`
+
+	rows := sqlmock.NewRows(employeeColumns).AddRow(
+		2, "Max", "Mustermann", "1998-04-18", "m", nil)
+
+	mock.ExpectQuery(regexp.QuoteMeta(query)).WithArgs("%mu%", "m", "1990-01-01", 51).WillReturnRows(rows)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	expectedResp := `[
+		{
+			"id": 2,
+			"firstName": "Max",
+			"lastName": "Mustermann",
+			"birthDay": "1998-04-18",
+			"gender": "m"
+		}
+	]`
+	assert := assert.New(t)
+	assert.Equal(http.StatusOK, w.Code, "expected http Code 200")
+	assert.JSONEq(expectedResp, w.Body.String(), "Response body doesn't match")
+
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// Sorting by an unknown field returns 400 with the allowed fields
+func TestGetEmployeesUnknownSort(t *testing.T) {
+	//Init mock db
+	db, mock := newMock()
+
+	store := postgres.New(db)
+	h := handlers.New(store, store, store)
+	//Init router
+	router := gin.Default()
+	router.GET("/employees", h.GetEmployees) //get all employees
+
+	//http request
+	req, _ := http.NewRequest("GET", "/employees?sort=salary", nil)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	expectedResp := `{
+			"code": "bad_request",
+			"message": "unknown sort field \"salary\", allowed fields: birthDay, firstName, gender, id, lastName",
+			"details": {
+				"allowed": ["birthDay", "firstName", "gender", "id", "lastName"]
+			}
+		}`
+	assert := assert.New(t)
+	assert.Equal(http.StatusBadRequest, w.Code, "expected http Code 400")
+	assert.JSONEq(expectedResp, w.Body.String(), "Response body doesn't match")
+
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// Querying an event that doesn't exist returns 404 with the request id
+func TestGetEventNotFound(t *testing.T) {
+	//Init mock db
+	db, mock := newMock()
+
+	store := postgres.New(db)
+	h := handlers.New(store, store, store)
+	//Init router
+	router := gin.Default()
+	router.GET("/events/:id", h.GetEvent) //get specific event
+
+	//http request
+	req, _ := http.NewRequest("GET", "/events/42", nil)
+	req.Header.Set("X-Request-ID", "req-1")
+
+	mock.ExpectQuery(regexp.QuoteMeta(
+		"SELECT * FROM events WHERE id = $1")).WithArgs(42).WillReturnError(sql.ErrNoRows)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	expectedResp := `{
+			"code": "not_found",
+			"message": "event not found",
+			"requestId": "req-1"
+		}`
+	assert := assert.New(t)
+	assert.Equal(http.StatusNotFound, w.Code, "expected http Code 404")
+	assert.JSONEq(expectedResp, w.Body.String(), "Response body doesn't match")
+
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// Database errors are mapped to status codes without leaking the database error
+func TestPostEmployeeDBErrors(t *testing.T) {
+	assert := assert.New(t)
+	for _, tc := range []struct {
+		err    error
+		status int
+		code   string
+	}{
+		{&pq.Error{Code: "23505", Message: "duplicate key value violates unique constraint"}, http.StatusConflict, "conflict"},
+		{&pq.Error{Code: "23503", Message: "violates foreign key constraint"}, http.StatusUnprocessableEntity, "unprocessable_entity"},
+		{&pq.Error{Code: "23514", Message: "violates check constraint"}, http.StatusBadRequest, "bad_request"},
+		{errors.New("connection refused"), http.StatusInternalServerError, "internal_server_error"},
+	} {
+		//Init mock db
+		db, mock := newMock()
+
+		store := postgres.New(db)
+		h := handlers.New(store, store, store)
+		//Init router
+		router := gin.Default()
+		router.POST("/employees", h.PostEmployee) //registers new employee
+
+		//http request
+		req, _ := http.NewRequest("POST", "/employees", strings.NewReader(
+			`{"firstName": "Joe", "lastName": "Jones", "birthday": "1997-09-12", "gender": "m"}`))
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(
+			`INSERT INTO employees (first_name, last_name, birthday, gender) VALUES ($1, $2, $3, $4) RETURNING id`)).WillReturnError(tc.err)
+		mock.ExpectRollback()
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var resp models.Error
+		assert.NoError(json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(tc.status, w.Code, "http Code doesn't match for %v", tc.err)
+		assert.Equal(tc.code, resp.Code, "error code doesn't match for %v", tc.err)
+		assert.NotContains(resp.Message, tc.err.Error(), "database error was leaked")
+
+		// we make sure that all expectations were met
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	}
+}
+
+// Bodies that can't be decoded are rejected without the messages of the JSON decoder
+func TestMalformedBody(t *testing.T) {
+	store := memory.New()
+	assert := assert.New(t)
+	assert.NoError(store.Seed(context.Background()))
+	h := handlers.New(store, store, store)
+	//Init router
+	router := gin.Default()
+	router.POST("/employees", h.PostEmployee)                            //registers new employee
+	router.PUT("/employees/:id", h.PutEmployee)                          //replace employees info
+	router.PATCH("/employees/:id", h.PatchEmployee)                      //update employees info with a merge patch
+	router.PATCH("/events/:id/employees/:employeeId", h.PatchAttendance) //set or toggle accommodation
+
+	for _, tc := range []struct {
+		method, url, body string
+		expectedCode      int
+		expectedMessage   string
+	}{
+		{"POST", "/employees", ``, http.StatusBadRequest, "request body is empty"},
+		{"POST", "/employees", `{"firstName": "Joe",`, http.StatusBadRequest, "malformed JSON"},
+		{"PUT", "/employees/2", `[1]`, http.StatusBadRequest, "request body must be an object"},
+		{"PATCH", "/employees/2", `[1]`, http.StatusBadRequest, "request body must be an object"},
+		{"PATCH", "/employees/2", `{"lastName": "Dude"} {}`, http.StatusBadRequest, "malformed JSON"},
+		{"POST", "/employees", `{"firstName": 5, "lastName": "Jones", "birthDay": "1990-01-01", "gender": "m"}`,
+			http.StatusUnprocessableEntity, "invalid fields: firstName"},
+		{"PATCH", "/events/1/employees/1", `{"accommodation": "yes"}`, http.StatusUnprocessableEntity, "invalid fields: accommodation"},
+	} {
+		req, _ := http.NewRequest(tc.method, tc.url, strings.NewReader(tc.body))
+		req.Header.Set("Content-Type", "application/merge-patch+json")
+		if tc.method != "PATCH" || strings.Contains(tc.url, "/events/") {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var resp struct {
+			Message string `json:"message"`
+		}
+		assert.NoError(json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(tc.expectedCode, w.Code, "http Code doesn't match for %s %s", tc.method, tc.body)
+		assert.Equal(tc.expectedMessage, resp.Message, "message doesn't match for %s %s", tc.method, tc.body)
+		assert.NotContains(w.Body.String(), "json:", "decoder message leaked for %s %s", tc.method, tc.body)
+	}
+}
+
+// Invalid employees are rejected with a list of the invalid fields
+func TestPostEmployeeInvalid(t *testing.T) {
+	//Init mock db
+	db, mock := newMock()
+
+	store := postgres.New(db)
+	h := handlers.New(store, store, store)
+	h.Now = func() time.Time { return today }
+	//Init router
+	router := gin.Default()
+	router.POST("/employees", h.PostEmployee) //registers new employee
+
+	assert := assert.New(t)
+	for _, tc := range []struct {
+		body, expectedDetails string
+	}{
+		{`{"firstName": " ", "gender": "xyz"}`, `[
+			{"field": "firstName", "message": "must not be blank"},
+			{"field": "lastName", "message": "is required"},
+			{"field": "birthDay", "message": "is required"},
+			{"field": "gender", "message": "must be one of m, f, d"}
+		]`},
+		{`{"firstName": "Joe", "lastName": "Jones", "birthDay": "banana", "gender": "m"}`, `[
+			{"field": "birthDay", "message": "must be a date in the format YYYY-MM-DD"}
+		]`},
+		{`{"firstName": "Joe", "lastName": "Jones", "birthDay": "2023-01-01", "gender": "m"}`, `[
+			{"field": "birthDay", "message": "must not be in the future"}
+		]`},
+		{`{"firstName": ["Joe"], "lastName": "Jones", "birthDay": "1990-01-01", "gender": "m"}`, `[
+			{"field": "firstName", "message": "must be a string"}
+		]`},
+	} {
+		req, _ := http.NewRequest("POST", "/employees", strings.NewReader(tc.body))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var resp struct {
+			Details json.RawMessage `json:"details"`
+		}
+		assert.NoError(json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(http.StatusUnprocessableEntity, w.Code, "expected http Code 422 for %s", tc.body)
+		assert.JSONEq(tc.expectedDetails, string(resp.Details), "invalid fields don't match for %s", tc.body)
+	}
+
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// event repository serving fixed events without a database
+type fakeEvents struct {
+	repository.EventRepository
+	events    map[int]models.Event
+	attendees map[int]int
+}
+
+func (f fakeEvents) GetEvent(ctx context.Context, id int) (models.Event, error) {
+	event, ok := f.events[id]
+	if !ok {
+		return event, &repository.NotFoundError{Entity: "event", ID: id}
+	}
+	return event, nil
+}
+
+func (f fakeEvents) DeleteEvent(ctx context.Context, id int, cascade bool) (models.Event, error) {
+	event, err := f.GetEvent(ctx, id)
+	if err != nil {
+		return event, err
+	}
+	if n := f.attendees[id]; n > 0 && !cascade {
+		return models.Event{}, &repository.HasAttendeesError{Attendees: n}
+	}
+	delete(f.events, id)
+	return event, nil
+}
+
+// The handlers only depend on the repository interfaces and work with any implementation
+func TestEventsFakeRepository(t *testing.T) {
+	events := fakeEvents{
+		events:    map[int]models.Event{1: {ID: 1, Name: "Summer Party", Date: date("2022-08-05")}},
+		attendees: map[int]int{1: 2},
+	}
+	h := handlers.New(nil, events, nil)
+	//Init router
+	router := gin.Default()
+	router.GET("/events/:id", h.GetEvent)       //get specific event
+	router.DELETE("/events/:id", h.DeleteEvent) //delete specified event
+
+	assert := assert.New(t)
+	for _, tc := range []struct {
+		method, url  string
+		expectedCode int
+		expectedResp string
+	}{
+		{"GET", "/events/1", http.StatusOK, `{"id": 1, "name": "Summer Party", "date": "2022-08-05"}`},
+		{"GET", "/events/2", http.StatusNotFound, `{"code": "not_found", "message": "event 2 not found"}`},
+		{"DELETE", "/events/1", http.StatusConflict, `{"code": "conflict",
+			"message": "event has 2 registered employees, use cascade=true to delete them as well"}`},
+		{"DELETE", "/events/1?cascade=true", http.StatusOK, `{"id": 1, "name": "Summer Party", "date": "2022-08-05"}`},
+		{"GET", "/events/1", http.StatusNotFound, `{"code": "not_found", "message": "event 1 not found"}`},
+	} {
+		req, _ := http.NewRequest(tc.method, tc.url, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(tc.expectedCode, w.Code, "http Code doesn't match for %s %s", tc.method, tc.url)
+		assert.JSONEq(tc.expectedResp, w.Body.String(), "Response body doesn't match for %s %s", tc.method, tc.url)
+	}
+}
+
+// Deleting an event with its attendances records the deletions in the order of the employees
+func TestDeleteEventAuditOrder(t *testing.T) {
+	store := memory.New()
+	ctx := context.Background()
+	assert := assert.New(t)
+	assert.NoError(store.Seed(ctx))
+	//employees 1 and 3 attend event 1
+	for _, employeeID := range []int{4, 2} {
+		_, err := store.CreateAttendance(ctx, models.Attendance{EventID: 1, EmployeeID: employeeID})
+		assert.NoError(err)
+	}
+
+	_, err := store.DeleteEvent(ctx, 1, true)
+	assert.NoError(err)
+
+	entries, err := store.ListAuditEntries(ctx, repository.AuditFilter{Entity: "attendance"}, repository.Page{Limit: 100})
+	assert.NoError(err)
+	var deleted []string
+	for _, entry := range entries {
+		if entry.Action == "delete" {
+			deleted = append(deleted, entry.EntityID)
+		}
+	}
+	assert.Equal([]string{"1/1", "1/2", "1/3", "1/4"}, deleted)
+}
+
+// Registering an employee for an event works end to end on the in-memory store
+func TestMemoryStore(t *testing.T) {
+	store := memory.New()
+	store.Now = func() time.Time { return registeredAt }
+	h := handlers.New(store, store, store)
+	h.Now = func() time.Time { return today }
+	//Init router
+	router := gin.Default()
+	router.POST("/employees", h.PostEmployee)                   //registers new employee
+	router.POST("/events", h.PostEvent)                         //registers new event
+	router.POST("/events/:id/employees", h.PostAttendance)      //register employee for event
+	router.GET("/events/:id/employees", h.GetEmployeesForEvent) //employees attending the event
+	router.GET("/employees/:id/events", h.GetEventsForEmployee) //events of the employee
+	router.DELETE("/events/:id", h.DeleteEvent)                 //delete specified event
+
+	assert := assert.New(t)
+	for _, tc := range []struct {
+		method, url, body string
+		expectedCode      int
+		expectedResp      string
+	}{
+		{"POST", "/employees", `{"firstName": "Joe", "lastName": "Jones", "birthDay": "1990-05-17", "gender": "m"}`, http.StatusCreated,
+			`{"id": 1, "firstName": "Joe", "lastName": "Jones", "birthDay": "1990-05-17", "gender": "m"}`},
+		{"POST", "/events", `{"name": "Summer Party", "date": "2022-08-05"}`, http.StatusCreated,
+			`{"id": 1, "name": "Summer Party", "date": "2022-08-05"}`},
+		{"POST", "/events/1/employees", `{"employeeId": 1, "accommodation": true}`, http.StatusCreated,
+			`{"eventId": 1, "employeeId": 1, "accommodation": true, "registeredAt": "2022-07-01T09:00:00Z", "status": "registered"}`},
+		{"POST", "/events/1/employees", `{"employeeId": 1}`, http.StatusConflict,
+			`{"code": "conflict", "message": "employee is already registered for this event"}`},
+		{"POST", "/events/1/employees", `{"employeeId": 2}`, http.StatusNotFound,
+			`{"code": "not_found", "message": "employee 2 not found"}`},
+		{"GET", "/events/1/employees?accommodation=true", "", http.StatusOK, `[{"id": 1, "firstName": "Joe", "lastName": "Jones",
+			"birthDay": "1990-05-17", "gender": "m", "accommodation": true, "registeredAt": "2022-07-01T09:00:00Z", "status": "registered"}]`},
+		{"GET", "/employees/1/events?upcoming=true", "", http.StatusOK, `[{"id": 1, "name": "Summer Party", "date": "2022-08-05",
+			"accommodation": true, "registeredAt": "2022-07-01T09:00:00Z", "status": "registered"}]`},
+		{"DELETE", "/events/1", "", http.StatusConflict, `{"code": "conflict",
+			"message": "event has 1 registered employees, use cascade=true to delete them as well"}`},
+		{"DELETE", "/events/1?cascade=true", "", http.StatusOK, `{"id": 1, "name": "Summer Party", "date": "2022-08-05"}`},
+		{"GET", "/employees/1/events", "", http.StatusOK, `null`},
+	} {
+		req, _ := http.NewRequest(tc.method, tc.url, strings.NewReader(tc.body))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(tc.expectedCode, w.Code, "http Code doesn't match for %s %s", tc.method, tc.url)
+		assert.JSONEq(tc.expectedResp, w.Body.String(), "Response body doesn't match for %s %s", tc.method, tc.url)
+	}
+}
+
+// Pending migrations are applied in order and recorded in schema_migrations
+func TestMigrateUp(t *testing.T) {
+	//Init mock db
+	db, mock := newMock()
+
+	migrations, err := pkgdb.Migrations()
+	assert := assert.New(t)
+	assert.NoError(err)
+	assert.Equal([]int{1, 2, 3}, []int{migrations[0].Version, migrations[1].Version, migrations[2].Version})
+
+	mock.ExpectExec(regexp.QuoteMeta("CREATE TABLE IF NOT EXISTS schema_migrations")).WillReturnResult(sqlmock.NewResult(0, 0))
+	//the first migration was applied already
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("SELECT pg_advisory_xact_lock($1)")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT EXISTS(SELECT 1 FROM schema_migrations WHERE version = $1)")).WithArgs(1).WillReturnRows(
+		sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectRollback()
+	for _, m := range migrations[1:] {
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta("SELECT pg_advisory_xact_lock($1)")).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT EXISTS(SELECT 1 FROM schema_migrations WHERE version = $1)")).WithArgs(m.Version).WillReturnRows(
+			sqlmock.NewRows([]string{"exists"}).AddRow(false))
+		mock.ExpectExec(regexp.QuoteMeta(m.Up)).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(regexp.QuoteMeta("INSERT INTO schema_migrations (version, name) VALUES ($1, $2)")).WithArgs(
+			m.Version, m.Name).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+	}
+
+	applied, err := pkgdb.MigrateUp(context.Background(), db)
+	assert.NoError(err)
+	assert.Equal(migrations[1:], applied)
+
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// Reverting migrations drops them in reverse order and removes them from schema_migrations
+func TestMigrateDown(t *testing.T) {
+	//Init mock db
+	db, mock := newMock()
+
+	migrations, err := pkgdb.Migrations()
+	assert := assert.New(t)
+	assert.NoError(err)
+
+	mock.ExpectExec(regexp.QuoteMeta("CREATE TABLE IF NOT EXISTS schema_migrations")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT version, applied_at FROM schema_migrations")).WillReturnRows(
+		sqlmock.NewRows([]string{"version", "applied_at"}).AddRow(1, registeredAt).AddRow(2, registeredAt).AddRow(3, registeredAt))
+	for _, m := range []pkgdb.Migration{migrations[2], migrations[1]} {
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta("SELECT pg_advisory_xact_lock($1)")).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT EXISTS(SELECT 1 FROM schema_migrations WHERE version = $1)")).WithArgs(m.Version).WillReturnRows(
+			sqlmock.NewRows([]string{"exists"}).AddRow(true))
+		mock.ExpectExec(regexp.QuoteMeta(m.Down)).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(regexp.QuoteMeta("DELETE FROM schema_migrations WHERE version = $1")).WithArgs(
+			m.Version).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+	}
+
+	reverted, err := pkgdb.MigrateDown(context.Background(), db, 2)
+	assert.NoError(err)
+	assert.Equal([]pkgdb.Migration{migrations[2], migrations[1]}, reverted)
+
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// The database config is read from the config file, the environment and flags, later sources win
+func TestDBConfig(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "db.env")
+	err := os.WriteFile(file, []byte("DB_HOST=db.internal\nDBUSER=file-user\nDBNAME=events\nDB_MAX_OPEN_CONNS=5\n"), 0o600)
+	assert := assert.New(t)
+	assert.NoError(err)
+	t.Setenv("DB_CONFIG_FILE", file)
+	t.Setenv("DBUSER", "env-user")
+	t.Setenv("DBPASS", "it's secret")
+	t.Setenv("DB_SSLMODE", "verify-full")
+
+	cfg := pkgdb.DefaultConfig()
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	cfg.RegisterFlags(flags)
+	assert.NoError(flags.Parse([]string{"-db-sslmode=require", "-db-conn-max-lifetime=1h"}))
+	assert.NoError(cfg.Load())
+
+	assert.Equal("db.internal", cfg.Host)
+	assert.Equal(5432, cfg.Port)
+	assert.Equal(5, cfg.MaxOpenConns)
+	assert.Equal(time.Hour, cfg.ConnMaxLifetime)
+	assert.Equal(`host='db.internal' port=5432 user='env-user' password='it\'s secret' dbname='events' sslmode='require'`, cfg.DSN())
+
+	//the URL replaces the other connection settings
+	t.Setenv("DATABASE_URL", "postgres://app@db.internal/events?sslmode=verify-full")
+	assert.NoError(cfg.Load())
+	assert.Equal("postgres://app@db.internal/events?sslmode=verify-full", cfg.DSN())
+
+	//invalid values are rejected
+	t.Setenv("DB_PORT", "abc")
+	assert.EqualError(cfg.Load(), `DB_PORT: invalid number "abc"`)
+	assert.Error(flags.Parse([]string{"-db-retry-backoff=soon"}))
+	//the password is only read from the config file and the environment
+	assert.Nil(flags.Lookup("db-password"))
+}
+
+// On shutdown the server stops accepting connections but finishes in-flight requests
+func TestServeGracefulShutdown(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert := assert.New(t)
+	assert.NoError(err)
+
+	started := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(100 * time.Millisecond)
+		w.Write([]byte("done"))
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- serveListener(ctx, ln, serverConfig{shutdownTimeout: time.Second}, handler)
+	}()
+
+	type response struct {
+		body string
+		err  error
+	}
+	responses := make(chan response, 1)
+	go func() {
+		resp, err := http.Get("http://" + ln.Addr().String())
+		if err != nil {
+			responses <- response{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		responses <- response{string(body), err}
+	}()
+
+	//shut down while the request is handled
+	<-started
+	cancel()
+
+	resp := <-responses
+	assert.NoError(resp.err)
+	assert.Equal("done", resp.body)
+	assert.NoError(<-served)
+	_, err = http.Get("http://" + ln.Addr().String())
+	assert.Error(err, "server still accepts connections after shutdown")
+}
+
+// The readiness probe checks the database connection and tables and reports the pool statistics
+func TestReadyz(t *testing.T) {
+	assert := assert.New(t)
+	migrations, err := pkgdb.Migrations()
+	assert.NoError(err)
+	latest := migrations[len(migrations)-1].Version
+	for _, tc := range []struct {
+		name           string
+		expect         func(mock sqlmock.Sqlmock)
+		expectedCode   int
+		expectedStatus string
+		expectedError  string
+	}{
+		{"ready", func(mock sqlmock.Sqlmock) {
+			mock.ExpectPing()
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT name FROM unnest($1::text[]) AS name WHERE to_regclass(name) IS NULL")).WillReturnRows(
+				sqlmock.NewRows([]string{"name"}))
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT COALESCE(MAX(version), 0) FROM schema_migrations")).WillReturnRows(
+				sqlmock.NewRows([]string{"max"}).AddRow(latest))
+		}, http.StatusOK, "ok", ""},
+		{"outdated schema", func(mock sqlmock.Sqlmock) {
+			mock.ExpectPing()
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT name FROM unnest($1::text[]) AS name WHERE to_regclass(name) IS NULL")).WillReturnRows(
+				sqlmock.NewRows([]string{"name"}))
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT COALESCE(MAX(version), 0) FROM schema_migrations")).WillReturnRows(
+				sqlmock.NewRows([]string{"max"}).AddRow(latest - 1))
+		}, http.StatusServiceUnavailable, "unavailable", "the schema is outdated, pending migrations haven't been applied"},
+		{"missing tables", func(mock sqlmock.Sqlmock) {
+			mock.ExpectPing()
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT name FROM unnest($1::text[]) AS name WHERE to_regclass(name) IS NULL")).WillReturnRows(
+				sqlmock.NewRows([]string{"name"}).AddRow("attendances"))
+		}, http.StatusServiceUnavailable, "unavailable", "tables are missing, the migrations haven't been applied"},
+		{"unreachable", func(mock sqlmock.Sqlmock) {
+			mock.ExpectPing().WillReturnError(errors.New("dial tcp 10.0.0.1:5432: connection refused"))
+		}, http.StatusServiceUnavailable, "unavailable", "database is unreachable"},
+	} {
+		//Init mock db
+		db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+		assert.NoError(err)
+		tc.expect(mock)
+
+		health := handlers.NewHealth(db, time.Second)
+		//Init router
+		router := gin.Default()
+		router.GET("/readyz", health.Readyz) //database is reachable and migrated
+
+		req, _ := http.NewRequest("GET", "/readyz", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var resp struct {
+			Status   string `json:"status"`
+			Database struct {
+				Status                string         `json:"status"`
+				Error                 string         `json:"error"`
+				MissingTables         []string       `json:"missingTables"`
+				SchemaVersion         int            `json:"schemaVersion"`
+				ExpectedSchemaVersion int            `json:"expectedSchemaVersion"`
+				Pool                  map[string]any `json:"pool"`
+			} `json:"database"`
+		}
+		assert.NoError(json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(tc.expectedCode, w.Code, "http Code doesn't match for %s", tc.name)
+		assert.Equal(tc.expectedStatus, resp.Status, "status doesn't match for %s", tc.name)
+		assert.Equal(tc.expectedStatus, resp.Database.Status, "database status doesn't match for %s", tc.name)
+		assert.Equal(tc.expectedError, resp.Database.Error, "error doesn't match for %s", tc.name)
+		assert.Contains(resp.Database.Pool, "openConnections", "pool stats are missing for %s", tc.name)
+		if tc.name == "missing tables" {
+			assert.Equal([]string{"attendances"}, resp.Database.MissingTables)
+		}
+		if tc.name == "outdated schema" {
+			assert.Equal(latest-1, resp.Database.SchemaVersion)
+			assert.Equal(latest, resp.Database.ExpectedSchemaVersion)
+		}
+
+		// we make sure that all expectations were met
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations for %s: %s", tc.name, err)
+		}
+	}
+}
+
+// A query running longer than the query timeout is canceled and reported as 504
+func TestGetEventTimeout(t *testing.T) {
+	//Init mock db
+	db, mock := newMock()
+
+	store := postgres.New(db)
+	store.QueryTimeout = 20 * time.Millisecond
+	h := handlers.New(store, store, store)
+	//Init router
+	router := gin.Default()
+	router.GET("/events/:id", h.GetEvent) //get specific event
+
+	//http request
+	req, _ := http.NewRequest("GET", "/events/1", nil)
+
+	mock.ExpectQuery(regexp.QuoteMeta(
+		"SELECT * FROM events WHERE id = $1")).WithArgs(1).WillDelayFor(time.Second).WillReturnRows(
+		sqlmock.NewRows([]string{"id", "name", "date"}).AddRow(1, "Summer Party", "2022-08-05"))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	expectedResp := `{
+			"code": "gateway_timeout",
+			"message": "the request timed out"
+		}`
+	assert := assert.New(t)
+	assert.Equal(http.StatusGatewayTimeout, w.Code, "expected http Code 504")
+	assert.JSONEq(expectedResp, w.Body.String(), "Response body doesn't match")
+
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// Every request gets an id that is returned in the header, error responses and log lines,
+// personal data is redacted from the logs
+func TestRequestIDLogging(t *testing.T) {
+	var logs bytes.Buffer
+	logger := logging.New(&logs, slog.LevelInfo)
+
+	store := memory.New()
+	employee, _ := store.CreateEmployee(context.Background(),
+		models.Employee{FirstName: "Anna", LastName: "Schmidt", BirthDay: date("1988-03-14"), Gender: "f"})
+	h := handlers.New(store, store, store)
+	//Init router
+	router := gin.New()
+	router.Use(handlers.RequestID(logger), handlers.AccessLog())
+	router.GET("/employees", h.GetEmployees) //get all employees
+	router.GET("/events/:id", h.GetEvent)    //get specific event
+
+	assert := assert.New(t)
+
+	//a generated id
+	req, _ := http.NewRequest("GET", "/employees?name=Anna&gender=f", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	generatedID := w.Header().Get("X-Request-ID")
+	assert.Regexp("^[0-9a-f]{32}$", generatedID)
+
+	//an id passed by the client is propagated to the error response
+	req, _ = http.NewRequest("GET", "/events/42", nil)
+	req.Header.Set("X-Request-ID", "req-1")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal("req-1", w.Header().Get("X-Request-ID"))
+	assert.JSONEq(`{"code": "not_found", "message": "event not found", "requestId": "req-1"}`, w.Body.String())
+
+	logger.Info("updated employee", "employee", employee, "lastName", employee.LastName, "gender", employee.Gender)
+
+	var lines []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(logs.String()), "\n") {
+		var entry map[string]any
+		assert.NoError(json.Unmarshal([]byte(line), &entry), "log line isn't JSON: %s", line)
+		lines = append(lines, entry)
+	}
+	assert.Len(lines, 3)
+	assert.Equal(generatedID, lines[0]["request_id"])
+	assert.Equal("/employees", lines[0]["route"])
+	assert.Equal("gender=%5BREDACTED%5D&name=%5BREDACTED%5D", lines[0]["query"])
+	assert.Equal(float64(http.StatusOK), lines[0]["status"])
+	assert.Equal("req-1", lines[1]["request_id"])
+	assert.Equal("/events/:id", lines[1]["route"])
+	assert.Equal(map[string]any{"id": float64(employee.ID)}, lines[2]["employee"])
+	assert.Equal(logging.Redacted, lines[2]["lastName"])
+	assert.Equal(logging.Redacted, lines[2]["gender"])
+	assert.NotContains(logs.String(), "Anna")
+	assert.NotContains(logs.String(), "Schmidt")
+	assert.NotContains(logs.String(), "1988")
+}
+
+// Requests are counted by route template and status, the database pool is reported from db.Stats()
+func TestMetrics(t *testing.T) {
+	//Init mock db
+	db, _ := newMock()
+
+	store := memory.New()
+	h := handlers.New(store, store, store)
+	m := metrics.New()
+	m.RegisterDB(db, "postgres")
+	//Init router
+	router := gin.New()
+	router.Use(m.Middleware())
+	router.GET("/metrics", m.Handler())                         //metrics in the Prometheus text format
+	router.GET("/events/:id/employees", h.GetEmployeesForEvent) //employees attending the event
+
+	for _, url := range []string{"/events/1/employees", "/events/2/employees", "/unknown"} {
+		req, _ := http.NewRequest("GET", url, nil)
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	req, _ := http.NewRequest("GET", "/metrics", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert := assert.New(t)
+	assert.Equal(http.StatusOK, w.Code, "expected http Code 200")
+	for _, line := range []string{
+		`http_requests_total{method="GET",route="/events/:id/employees",status="200"} 2`,
+		`http_requests_total{method="GET",route="unmatched",status="404"} 1`,
+		`http_request_duration_seconds_count{method="GET",route="/events/:id/employees"} 2`,
+		`go_sql_open_connections{db_name="postgres"}`,
+		`go_sql_max_open_connections{db_name="postgres"}`,
+	} {
+		assert.Contains(w.Body.String(), line)
+	}
+}
+
+// signs claims with method and key for the auth tests
+func signToken(t *testing.T, method jwt.SigningMethod, key any, claims jwt.MapClaims) string {
+	token, err := jwt.NewWithClaims(method, claims).SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// Requests are authenticated with API keys or HS256/RS256 JWTs, everything else is rejected with 401
+func TestAuthenticate(t *testing.T) {
+	assert := assert.New(t)
+	store := memory.New()
+	apiKey, err := auth.GenerateAPIKey()
+	assert.NoError(err)
+	_, err = store.CreateAPIKey(context.Background(), models.APIKey{Name: "ci", Hash: auth.HashAPIKey(apiKey)})
+	assert.NoError(err)
+	revokedKey, _ := auth.GenerateAPIKey()
+	revoked, _ := store.CreateAPIKey(context.Background(), models.APIKey{Name: "old", Hash: auth.HashAPIKey(revokedKey)})
+	store.RevokeAPIKey(context.Background(), revoked.ID)
+
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(err)
+	authenticator := auth.New(store, auth.Config{HS256Secret: secret, RS256PublicKey: &rsaKey.PublicKey,
+		Issuer: "https://auth.example.com", Audience: "events-api"})
+
+	//Init router
+	router := gin.New()
+	router.Use(handlers.Authenticate(authenticator))
+	router.GET("/whoami", func(c *gin.Context) {
+		principal, _ := handlers.CurrentPrincipal(c)
+		c.IndentedJSON(http.StatusOK, principal)
+	})
+
+	valid := jwt.MapClaims{"sub": "alice", "iss": "https://auth.example.com", "aud": "events-api", "exp": time.Now().Add(time.Hour).Unix()}
+	withClaim := func(key string, value any) jwt.MapClaims {
+		claims := jwt.MapClaims{}
+		for k, v := range valid {
+			claims[k] = v
+		}
+		claims[key] = value
+		return claims
+	}
+	rsaPublicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: x509.MarshalPKCS1PublicKey(&rsaKey.PublicKey)})
+
+	for _, tc := range []struct {
+		name          string
+		header, value string
+		expectedCode  int
+		expectedResp  string
+	}{
+		{"API key", "X-API-Key", apiKey, http.StatusOK, `{"subject": "ci", "method": "api_key"}`},
+		{"API key as bearer token", "Authorization", "Bearer " + apiKey, http.StatusOK, `{"subject": "ci", "method": "api_key"}`},
+		{"HS256", "Authorization", "Bearer " + signToken(t, jwt.SigningMethodHS256, secret, valid), http.StatusOK,
+			`{"subject": "alice", "method": "jwt"}`},
+		{"RS256", "Authorization", "Bearer " + signToken(t, jwt.SigningMethodRS256, rsaKey, valid), http.StatusOK,
+			`{"subject": "alice", "method": "jwt"}`},
+		{"no credentials", "", "", http.StatusUnauthorized,
+			`{"code": "unauthorized", "message": "authentication required, send an API key or a bearer token"}`},
+		{"unknown API key", "X-API-Key", "mk_unknown", http.StatusUnauthorized,
+			`{"code": "unauthorized", "message": "invalid credentials: unknown API key"}`},
+		{"revoked API key", "X-API-Key", revokedKey, http.StatusUnauthorized,
+			`{"code": "unauthorized", "message": "invalid credentials: API key was revoked"}`},
+		{"basic auth", "Authorization", "Basic YWxpY2U6c2VjcmV0", http.StatusUnauthorized,
+			`{"code": "unauthorized", "message": "invalid credentials: expected Authorization: Bearer <token>"}`},
+		{"expired", "Authorization", "Bearer " + signToken(t, jwt.SigningMethodHS256, secret,
+			withClaim("exp", time.Now().Add(-time.Hour).Unix())), http.StatusUnauthorized,
+			`{"code": "unauthorized", "message": "invalid credentials: token has invalid claims: token is expired"}`},
+		{"wrong issuer", "Authorization", "Bearer " + signToken(t, jwt.SigningMethodHS256, secret,
+			withClaim("iss", "https://evil.example.com")), http.StatusUnauthorized,
+			`{"code": "unauthorized", "message": "invalid credentials: token has invalid claims: token has invalid issuer"}`},
+		{"wrong audience", "Authorization", "Bearer " + signToken(t, jwt.SigningMethodHS256, secret,
+			withClaim("aud", "other-api")), http.StatusUnauthorized,
+			`{"code": "unauthorized", "message": "invalid credentials: token has invalid claims: token has invalid audience"}`},
+		{"wrong secret", "Authorization", "Bearer " + signToken(t, jwt.SigningMethodHS256, []byte("another secret of 32 characters!"), valid),
+			http.StatusUnauthorized, `{"code": "unauthorized", "message": "invalid credentials: token signature is invalid: signature is invalid"}`},
+		//the RS256 public key must not be accepted as HS256 secret
+		{"algorithm confusion", "Authorization", "Bearer " + signToken(t, jwt.SigningMethodHS256, rsaPublicPEM, valid),
+			http.StatusUnauthorized, `{"code": "unauthorized", "message": "invalid credentials: token signature is invalid: signature is invalid"}`},
+	} {
+		req, _ := http.NewRequest("GET", "/whoami", nil)
+		if tc.header != "" {
+			req.Header.Set(tc.header, tc.value)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(tc.expectedCode, w.Code, "http Code doesn't match for %s", tc.name)
+		assert.JSONEq(tc.expectedResp, w.Body.String(), "Response body doesn't match for %s", tc.name)
+		if tc.expectedCode == http.StatusUnauthorized {
+			assert.Contains(w.Header().Get("WWW-Authenticate"), "Bearer", "WWW-Authenticate is missing for %s", tc.name)
+		}
+	}
+}
+
+// Routes require the permission of their policy, employees may only change their own record and registrations
+func TestAuthorize(t *testing.T) {
+	assert := assert.New(t)
+	store := memory.New()
+	assert.NoError(store.Seed(context.Background()))
+	h := handlers.New(store, store, store)
+	employeeID := 2
+	keys := map[string]string{}
+	for _, key := range []models.APIKey{
+		{Name: "admin", Role: auth.RoleAdmin},
+		{Name: "hr", Role: auth.RoleHR},
+		{Name: "organizer", Role: auth.RoleEventOrganizer},
+		{Name: "employee", Role: auth.RoleEmployee, EmployeeID: &employeeID},
+	} {
+		secret, _ := auth.GenerateAPIKey()
+		key.Hash = auth.HashAPIKey(secret)
+		_, err := store.CreateAPIKey(context.Background(), key)
+		assert.NoError(err)
+		keys[key.Name] = secret
+	}
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	exp := time.Now().Add(time.Hour).Unix()
+	keys["employee jwt"] = signToken(t, jwt.SigningMethodHS256, secret,
+		jwt.MapClaims{"sub": "max", "exp": exp, "roles": []string{"employee"}, "employee_id": 2})
+	keys["no roles jwt"] = signToken(t, jwt.SigningMethodHS256, secret, jwt.MapClaims{"sub": "bob", "exp": exp})
+	keys["unknown role jwt"] = signToken(t, jwt.SigningMethodHS256, secret,
+		jwt.MapClaims{"sub": "eve", "exp": exp, "roles": []string{"root"}})
+
+	//Init router
+	router := gin.New()
+	router.Use(handlers.Authenticate(auth.New(store, auth.Config{HS256Secret: secret})), handlers.Authorize(auth.RoutePolicies))
+	router.GET("/employees", h.GetEmployees)                               //get all employees
+	router.POST("/employees", h.PostEmployee)                              //registers new employee
+	router.PUT("/employees/:id", h.PutEmployee)                            //update employees info
+	router.DELETE("/employees/:id", h.DeleteEmployee)                      //delete specified employee
+	router.GET("/events", h.GetEvents)                                     //get all upcoming events
+	router.POST("/events", h.PostEvent)                                    //registers new event
+	router.POST("/events/:id/employees", h.PostAttendance)                 //register employee for event
+	router.PATCH("/events/:id/employees/:employeeId", h.PatchAttendance)   //set or toggle accommodation
+	router.DELETE("/events/:id/employees/:employeeId", h.DeleteAttendance) //withdraw employee from event
+	//route without policy
+	router.GET("/unprotected", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	employee := `{"firstName": "Joe", "lastName": "Jones", "birthDay": "1990-05-17", "gender": "m"}`
+	forbidden := func(permission, role string) string {
+		return `{"code": "forbidden", "message": "missing permission ` + permission + `",
+			"details": {"permission": "` + permission + `", "roles": ["` + role + `"]}}`
+	}
+	for _, tc := range []struct {
+		principal, method, url, body string
+		expectedCode                 int
+		expectedResp                 string
+	}{
+		{"hr", "POST", "/employees", employee, http.StatusCreated, ""},
+		{"organizer", "POST", "/employees", employee, http.StatusForbidden, forbidden("employees:create", "event-organizer")},
+		{"employee", "POST", "/employees", employee, http.StatusForbidden, forbidden("employees:create", "employee")},
+		{"organizer", "DELETE", "/employees/5", "", http.StatusForbidden, forbidden("employees:delete", "event-organizer")},
+		{"hr", "DELETE", "/employees/5", "", http.StatusOK, ""},
+		{"organizer", "POST", "/events", `{"name": "Offsite", "date": "2030-05-01"}`, http.StatusCreated, ""},
+		{"hr", "POST", "/events", `{"name": "Offsite", "date": "2030-05-01"}`, http.StatusForbidden, forbidden("events:write", "hr")},
+		{"employee", "GET", "/employees", "", http.StatusOK, ""},
+		{"employee", "PUT", "/employees/2", employee, http.StatusOK, ""},
+		{"employee", "PUT", "/employees/3", employee, http.StatusForbidden, forbidden("employees:update", "employee")},
+		{"employee", "POST", "/events/1/employees", `{"employeeId": 2}`, http.StatusCreated, ""},
+		{"employee", "POST", "/events/1/employees", `{"employeeId": 3}`, http.StatusForbidden, forbidden("attendances:write", "employee")},
+		{"employee", "POST", "/events/1/employees", `{}`, http.StatusForbidden, forbidden("attendances:write", "employee")},
+		{"employee", "POST", "/events/1/employees", `{"employeeId": 2, "EMPLOYEEID": 3}`, http.StatusForbidden,
+			forbidden("attendances:write", "employee")},
+		{"employee", "POST", "/events/1/employees", `{"employeeId": 2, "employeeId": 3}`, http.StatusForbidden,
+			forbidden("attendances:write", "employee")},
+		{"employee", "POST", "/events/3/employees", `{"EmployeeID": 2}`, http.StatusCreated, ""},
+		{"employee jwt", "PATCH", "/events/1/employees/2", "", http.StatusOK, ""},
+		{"employee jwt", "PATCH", "/events/1/employees/3", "", http.StatusForbidden, forbidden("attendances:write", "employee")},
+		{"employee", "DELETE", "/events/1/employees/2", "", http.StatusOK, ""},
+		{"organizer", "DELETE", "/events/1/employees/3", "", http.StatusOK, ""},
+		{"admin", "DELETE", "/employees/2", "", http.StatusOK, ""},
+		{"no roles jwt", "GET", "/events", "", http.StatusForbidden, `{"code": "forbidden", "message": "missing permission events:read",
+			"details": {"permission": "events:read", "roles": null}}`},
+		{"unknown role jwt", "GET", "/events", "", http.StatusUnauthorized,
+			`{"code": "unauthorized", "message": "invalid credentials: unknown role \"root\""}`},
+		{"admin", "GET", "/unprotected", "", http.StatusForbidden,
+			`{"code": "forbidden", "message": "no permission allows GET /unprotected"}`},
+	} {
+		req, _ := http.NewRequest(tc.method, tc.url, strings.NewReader(tc.body))
+		req.Header.Set("Authorization", "Bearer "+keys[tc.principal])
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(tc.expectedCode, w.Code, "http Code doesn't match for %s %s %s", tc.principal, tc.method, tc.url)
+		if tc.expectedResp != "" {
+			assert.JSONEq(tc.expectedResp, w.Body.String(), "Response body doesn't match for %s %s %s", tc.principal, tc.method, tc.url)
+		}
+	}
+}
+
+// The in-memory store gets an admin key, the apikey subcommand only manages the keys in postgres
+func TestDevelopmentKey(t *testing.T) {
+	assert := assert.New(t)
+	store := memory.New()
+	assert.NoError(store.Seed(context.Background()))
+	//a key set with MEMORY_API_KEY is used as it is
+	configured, err := createDevelopmentKey(context.Background(), store, "configured-key")
+	assert.NoError(err)
+	assert.Equal("configured-key", configured)
+	key, err := createDevelopmentKey(context.Background(), store, "")
+	assert.NoError(err)
+	//the generated key is only readable by the owner
+	file := filepath.Join(t.TempDir(), "api-key")
+	assert.NoError(os.WriteFile(file, nil, 0o644))
+	assert.NoError(writeKeyFile(file, key))
+	info, err := os.Stat(file)
+	assert.NoError(err)
+	assert.Equal(os.FileMode(0o600), info.Mode().Perm())
+	content, err := os.ReadFile(file)
+	assert.NoError(err)
+	assert.Equal(key+"\n", string(content))
+
+	//Init router, the audit log requires the admin or hr role
+	router := gin.New()
+	router.Use(handlers.Authenticate(auth.New(store, auth.Config{})), handlers.Authorize(auth.RoutePolicies))
+	router.GET("/audit", handlers.NewAudit(store).GetAudit) //changes to employees, events and attendances
+
+	req, _ := http.NewRequest("GET", "/audit", nil)
+	req.Header.Set("X-API-Key", key)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(http.StatusOK, w.Code, "http Code doesn't match")
+}
+
+// Changes are recorded in the audit log with the actor, request id and changed fields
+func TestAuditLog(t *testing.T) {
+	assert := assert.New(t)
+	store := memory.New()
+	store.Now = func() time.Time { return registeredAt }
+	h := handlers.New(store, store, store)
+	apiKey, _ := auth.GenerateAPIKey()
+	_, err := store.CreateAPIKey(context.Background(), models.APIKey{Name: "hr-portal", Hash: auth.HashAPIKey(apiKey), Role: auth.RoleHR})
+	assert.NoError(err)
+
+	//Init router
+	router := gin.New()
+	router.Use(handlers.RequestID(slog.New(slog.NewTextHandler(io.Discard, nil))), handlers.Authenticate(auth.New(store, auth.Config{})))
+	router.POST("/employees", h.PostEmployee)               //registers new employee
+	router.PUT("/employees/:id", h.PutEmployee)             //update employees info
+	router.DELETE("/employees/:id", h.DeleteEmployee)       //delete specified employee
+	router.GET("/audit", handlers.NewAudit(store).GetAudit) //changes to employees, events and attendances
+
+	for i, tc := range []struct {
+		method, url, body string
+	}{
+		{"POST", "/employees", `{"firstName": "Joe", "lastName": "Jones", "birthDay": "1990-05-17", "gender": "m"}`},
+		{"POST", "/employees", `{"firstName": "Ann", "lastName": "Lee", "birthDay": "1991-02-03", "gender": "f"}`},
+		{"PUT", "/employees/1", `{"firstName": "Joe", "lastName": "Miller", "birthDay": "1990-05-17", "gender": "m"}`},
+		{"DELETE", "/employees/1", ""},
+	} {
+		req, _ := http.NewRequest(tc.method, tc.url, strings.NewReader(tc.body))
+		req.Header.Set("X-API-Key", apiKey)
+		req.Header.Set("X-Request-ID", fmt.Sprintf("req-%d", i+1))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Less(w.Code, 300, "http Code doesn't match for %s %s", tc.method, tc.url)
+	}
+
+	req, _ := http.NewRequest("GET", "/audit?entity=employee&id=1", nil)
+	req.Header.Set("X-API-Key", apiKey)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	expectedResp := `[
+		{"id": 1, "occurredAt": "2022-07-01T09:00:00Z", "actor": "hr-portal", "action": "create", "entity": "employee", "entityId": "1",
+			"before": null, "after": {"id": 1, "firstName": "Joe", "lastName": "Jones", "birthDay": "1990-05-17", "gender": "m"}, "requestId": "req-1"},
+		{"id": 3, "occurredAt": "2022-07-01T09:00:00Z", "actor": "hr-portal", "action": "update", "entity": "employee", "entityId": "1",
+			"before": {"lastName": "Jones"}, "after": {"lastName": "Miller"}, "requestId": "req-3"},
+		{"id": 4, "occurredAt": "2022-07-01T09:00:00Z", "actor": "hr-portal", "action": "delete", "entity": "employee", "entityId": "1",
+			"before": {}, "after": {"deletedAt": "2022-07-01T09:00:00Z"}, "requestId": "req-4"}
+	]`
+	assert.Equal(http.StatusOK, w.Code, "http Code doesn't match")
+	assert.JSONEq(expectedResp, w.Body.String(), "Response body doesn't match")
+
+	req, _ = http.NewRequest("GET", "/audit?entity=user", nil)
+	req.Header.Set("X-API-Key", apiKey)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(http.StatusBadRequest, w.Code, "http Code doesn't match for unknown entity")
+}
+
+// Purging an employee deletes their attendances with an audit entry each and erases their data from the audit log
+func TestPurgeEmployee(t *testing.T) {
+	//Init mock db
+	db, mock := newMock()
+
+	store := postgres.New(db)
+	h := handlers.New(store, store, store)
+	//Init router
+	router := gin.Default()
+	router.POST("/employees/:id/purge", h.PurgeEmployee) //erase employee
+
+	//http request
+	req, _ := http.NewRequest("POST", "/employees/2/purge", nil)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(
+		"SELECT id FROM employees WHERE id = $1 FOR UPDATE")).WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectQuery(regexp.QuoteMeta(
+		"DELETE FROM attendances WHERE employee_id = $1 RETURNING")).WithArgs(2).WillReturnRows(
+		sqlmock.NewRows(attendanceColumns).AddRow(1, 2, true, registeredAt, "registered").AddRow(3, 2, false, registeredAt, "registered"))
+	expectAudit(mock, "delete", "attendance", "1/2")
+	expectAudit(mock, "delete", "attendance", "3/2")
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM employees WHERE id = $1")).WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(
+		"UPDATE audit_log SET before = NULL, after = NULL WHERE entity = $1 AND entity_id = $2")).WithArgs(
+		"employee", "2").WillReturnResult(sqlmock.NewResult(0, 3))
+	expectAudit(mock, "purge", "employee", "2")
+	mock.ExpectCommit()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert := assert.New(t)
+	assert.Equal(http.StatusNoContent, w.Code, "http Code doesn't match")
+
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// Deleted employees are hidden until they are restored, purging erases them and their data in the audit log
+func TestSoftDeleteEmployee(t *testing.T) {
+	store := memory.New()
+	store.Now = func() time.Time { return registeredAt }
+	assert := assert.New(t)
+	assert.NoError(store.Seed(context.Background()))
+	h := handlers.New(store, store, store)
+	//Init router
+	router := gin.Default()
+	router.GET("/employees", h.GetEmployees)                    //get all employees
+	router.PUT("/employees/:id", h.PutEmployee)                 //update employees info
+	router.DELETE("/employees/:id", h.DeleteEmployee)           //delete specified employee
+	router.POST("/employees/:id/restore", h.RestoreEmployee)    //restore deleted employee
+	router.POST("/employees/:id/purge", h.PurgeEmployee)        //erase employee
+	router.GET("/events/:id/employees", h.GetEmployeesForEvent) //employees attending the event
+	router.POST("/events/:id/employees", h.PostAttendance)      //register employee for event
+	router.GET("/audit", handlers.NewAudit(store).GetAudit)     //changes to employees, events and attendances
+
+	//employees 1 and 3 attend event 1
+	for _, tc := range []struct {
+		method, url, body string
+		expectedCode      int
+		expectedIDs       []int
+	}{
+		{"DELETE", "/employees/3", "", http.StatusOK, nil},
+		{"DELETE", "/employees/3", "", http.StatusNotFound, nil},
+		{"GET", "/employees", "", http.StatusOK, []int{1, 2, 4}},
+		{"GET", "/employees?include_deleted=true", "", http.StatusOK, []int{1, 2, 3, 4}},
+		{"GET", "/events/1/employees", "", http.StatusOK, []int{1}},
+		{"GET", "/events/1/employees?include_deleted=true", "", http.StatusOK, []int{1, 3}},
+		{"PUT", "/employees/3", `{"firstName": "Eva", "lastName": "Meyer", "birthDay": "1985-03-02", "gender": "f"}`, http.StatusNotFound, nil},
+		{"POST", "/events/2/employees", `{"employeeId": 3}`, http.StatusNotFound, nil},
+		{"POST", "/employees/3/restore", "", http.StatusOK, nil},
+		{"GET", "/events/1/employees", "", http.StatusOK, []int{1, 3}},
+		{"POST", "/employees/1/purge", "", http.StatusNoContent, nil},
+		{"POST", "/employees/1/purge", "", http.StatusNotFound, nil},
+		{"GET", "/employees?include_deleted=true", "", http.StatusOK, []int{2, 3, 4}},
+		{"GET", "/events/1/employees?include_deleted=true", "", http.StatusOK, []int{3}},
+	} {
+		req, _ := http.NewRequest(tc.method, tc.url, strings.NewReader(tc.body))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(tc.expectedCode, w.Code, "http Code doesn't match for %s %s", tc.method, tc.url)
+		if tc.expectedIDs != nil {
+			var employees []models.Employee
+			assert.NoError(json.Unmarshal(w.Body.Bytes(), &employees))
+			var ids []int
+			for _, employee := range employees {
+				ids = append(ids, employee.ID)
+			}
+			assert.Equal(tc.expectedIDs, ids, "employees don't match for %s %s", tc.method, tc.url)
+		}
+	}
+
+	//only the purge itself is left of the purged employee's history
+	req, _ := http.NewRequest("GET", "/audit?entity=employee&id=1", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	var entries []models.AuditEntry
+	assert.NoError(json.Unmarshal(w.Body.Bytes(), &entries))
+	var actions []string
+	for _, entry := range entries {
+		assert.Nil(entry.Before, "personal data wasn't erased from entry %d", entry.ID)
+		assert.Nil(entry.After, "personal data wasn't erased from entry %d", entry.ID)
+		actions = append(actions, entry.Action)
+	}
+	assert.Equal([]string{"create", "purge"}, actions)
+
+	//the attendances removed by the purge are recorded like other deletions
+	req, _ = http.NewRequest("GET", "/audit?entity=attendance&id=1/1", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	entries = nil
+	assert.NoError(json.Unmarshal(w.Body.Bytes(), &entries))
+	actions = nil
+	for _, entry := range entries {
+		actions = append(actions, entry.Action)
+	}
+	assert.Equal([]string{"create", "delete"}, actions)
+}
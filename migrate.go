@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mtp721/micobo-assignment/pkg/db"
+)
+
+const migrateUsage = `usage: migrate up|down [steps]|status
+  up      applies all pending migrations
+  down    reverts the last applied migration, or the last steps migrations
+  status  lists all migrations and when they were applied`
+
+// runs the migrate subcommand with its arguments and returns the exit code
+func runMigrate(cfg db.Config, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+	steps := 1
+	if args[0] == "down" && len(args) > 1 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 1 {
+			fmt.Fprintf(os.Stderr, "invalid number of steps %q\n", args[1])
+			return 2
+		}
+		steps = n
+	}
+
+	if err := cfg.Load(); err != nil {
+		fmt.Fprintf(os.Stderr, "loading database config: %v\n", err)
+		return 1
+	}
+	ctx := context.Background()
+	conn, err := db.Connect(ctx, cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer conn.Close()
+
+	var migrations []db.Migration
+	switch args[0] {
+	case "up":
+		migrations, err = db.MigrateUp(ctx, conn)
+		for _, m := range migrations {
+			fmt.Printf("applied %04d_%s\n", m.Version, m.Name)
+		}
+	case "down":
+		migrations, err = db.MigrateDown(ctx, conn, steps)
+		for _, m := range migrations {
+			fmt.Printf("reverted %04d_%s\n", m.Version, m.Name)
+		}
+	case "status":
+		err = printMigrationStatus(ctx, conn)
+	default:
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "migrate %s: %v\n", args[0], err)
+		return 1
+	}
+	return 0
+}
+
+// prints a table of all migrations and when they were applied
+func printMigrationStatus(ctx context.Context, conn *sql.DB) error {
+	status, err := db.Status(ctx, conn)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED")
+	for _, s := range status {
+		applied := "pending"
+		if s.AppliedAt != nil {
+			applied = s.AppliedAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%04d\t%s\t%s\n", s.Version, s.Name, applied)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,188 @@
+// Package auth authenticates requests with API keys stored in the database or with JWT bearer tokens.
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/mtp721/micobo-assignment/pkg/repository"
+)
+
+// prefix of API keys, it tells them apart from JWTs in the Authorization header
+const APIKeyPrefix = "mk_"
+
+// header API keys can be sent in besides the Authorization header
+const APIKeyHeader = "X-API-Key"
+
+// ways a principal authenticated with
+const (
+	MethodAPIKey = "api_key"
+	MethodJWT    = "jwt"
+)
+
+var (
+	// the request has no credentials
+	ErrNoCredentials = errors.New("missing credentials")
+	// the credentials are unknown, revoked, expired or invalid
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// Principal is the authenticated client of a request
+type Principal struct {
+	//name of the API key or subject of the JWT
+	Subject string `json:"subject"`
+	//MethodAPIKey or MethodJWT
+	Method string `json:"method"`
+	//roles granting the principal's permissions
+	Roles []string `json:"roles,omitempty"`
+	//id of the employee the principal is, 0 if it isn't an employee. Permissions ending in :own are
+	//only granted on this employee's record and registrations
+	EmployeeID int `json:"employeeId,omitempty"`
+}
+
+// claims of accepted JWTs
+type claims struct {
+	jwt.RegisteredClaims
+	Roles      []string `json:"roles"`
+	EmployeeID int      `json:"employee_id"`
+}
+
+// Config of the accepted JWTs, tokens are rejected if no key is set
+type Config struct {
+	//secret of HS256 signed tokens
+	HS256Secret []byte
+	//public key of RS256 signed tokens
+	RS256PublicKey *rsa.PublicKey
+	//required iss and aud claims, not checked if empty
+	Issuer   string
+	Audience string
+	//tolerated clock skew when checking exp and nbf
+	Leeway time.Duration
+}
+
+// Authenticator checks the credentials of requests
+type Authenticator struct {
+	keys   repository.APIKeyRepository
+	config Config
+}
+
+func New(keys repository.APIKeyRepository, config Config) *Authenticator {
+	return &Authenticator{keys: keys, config: config}
+}
+
+// returns a new random API key
+func GenerateAPIKey() (string, error) {
+	var b [32]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	return APIKeyPrefix + base64.RawURLEncoding.EncodeToString(b[:]), nil
+}
+
+// returns the hash an API key is stored with
+func HashAPIKey(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(hash[:])
+}
+
+// returns the credentials of r from the X-API-Key header or the bearer token of the Authorization header
+func credentials(r *http.Request) (string, error) {
+	if key := r.Header.Get(APIKeyHeader); key != "" {
+		return key, nil
+	}
+	header := r.Header.Get("Authorization")
+	if header == "" {
+		return "", ErrNoCredentials
+	}
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+		return "", fmt.Errorf("%w: expected Authorization: Bearer <token>", ErrInvalidCredentials)
+	}
+	return strings.TrimSpace(token), nil
+}
+
+// returns the principal of the request, errors wrap ErrNoCredentials or ErrInvalidCredentials
+// unless the API keys couldn't be looked up
+func (a *Authenticator) Authenticate(r *http.Request) (Principal, error) {
+	token, err := credentials(r)
+	if err != nil {
+		return Principal{}, err
+	}
+	if strings.HasPrefix(token, APIKeyPrefix) {
+		return a.authenticateAPIKey(r.Context(), token)
+	}
+	return a.authenticateJWT(token)
+}
+
+func (a *Authenticator) authenticateAPIKey(ctx context.Context, token string) (Principal, error) {
+	key, err := a.keys.GetAPIKeyByHash(ctx, HashAPIKey(token))
+	if errors.Is(err, repository.ErrNotFound) {
+		return Principal{}, fmt.Errorf("%w: unknown API key", ErrInvalidCredentials)
+	}
+	if err != nil {
+		return Principal{}, err
+	}
+	if key.RevokedAt != nil {
+		return Principal{}, fmt.Errorf("%w: API key was revoked", ErrInvalidCredentials)
+	}
+	principal := Principal{Subject: key.Name, Method: MethodAPIKey}
+	if key.Role != "" {
+		principal.Roles = []string{key.Role}
+	}
+	if key.EmployeeID != nil {
+		principal.EmployeeID = *key.EmployeeID
+	}
+	return principal, nil
+}
+
+func (a *Authenticator) authenticateJWT(token string) (Principal, error) {
+	var methods []string
+	if a.config.HS256Secret != nil {
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+	if a.config.RS256PublicKey != nil {
+		methods = append(methods, jwt.SigningMethodRS256.Alg())
+	}
+	if len(methods) == 0 {
+		return Principal{}, fmt.Errorf("%w: bearer tokens aren't accepted", ErrInvalidCredentials)
+	}
+
+	options := []jwt.ParserOption{jwt.WithValidMethods(methods), jwt.WithExpirationRequired(), jwt.WithLeeway(a.config.Leeway)}
+	if a.config.Issuer != "" {
+		options = append(options, jwt.WithIssuer(a.config.Issuer))
+	}
+	if a.config.Audience != "" {
+		options = append(options, jwt.WithAudience(a.config.Audience))
+	}
+	//the key is chosen by the algorithm checked by WithValidMethods, so an RS256 public key is never used as HS256 secret
+	var c claims
+	_, err := jwt.ParseWithClaims(token, &c, func(t *jwt.Token) (any, error) {
+		if t.Method.Alg() == jwt.SigningMethodHS256.Alg() {
+			return a.config.HS256Secret, nil
+		}
+		return a.config.RS256PublicKey, nil
+	}, options...)
+	if err != nil {
+		return Principal{}, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+
+	if c.Subject == "" {
+		return Principal{}, fmt.Errorf("%w: token has no subject", ErrInvalidCredentials)
+	}
+	for _, role := range c.Roles {
+		if !ValidRole(role) {
+			return Principal{}, fmt.Errorf("%w: unknown role %q", ErrInvalidCredentials, role)
+		}
+	}
+	return Principal{Subject: c.Subject, Method: MethodJWT, Roles: c.Roles, EmployeeID: c.EmployeeID}, nil
+}
@@ -0,0 +1,128 @@
+package auth
+
+// roles of principals, API keys have one role and JWTs list theirs in the roles claim
+const (
+	RoleAdmin          = "admin"
+	RoleHR             = "hr"
+	RoleEventOrganizer = "event-organizer"
+	RoleEmployee       = "employee"
+)
+
+// Roles lists all roles
+var Roles = []string{RoleAdmin, RoleHR, RoleEventOrganizer, RoleEmployee}
+
+// Permission allows an action on a kind of resource. Permissions ending in :own only allow the action
+// on the principal's own employee record or registrations
+type Permission string
+
+const (
+	EmployeesRead      Permission = "employees:read"
+	EmployeesCreate    Permission = "employees:create"
+	EmployeesUpdate    Permission = "employees:update"
+	EmployeesUpdateOwn Permission = "employees:update:own"
+	EmployeesDelete    Permission = "employees:delete"
+	EmployeesPurge     Permission = "employees:purge"
+
+	EventsRead  Permission = "events:read"
+	EventsWrite Permission = "events:write"
+
+	AttendancesRead     Permission = "attendances:read"
+	AttendancesWrite    Permission = "attendances:write"
+	AttendancesWriteOwn Permission = "attendances:write:own"
+
+	AuditRead Permission = "audit:read"
+)
+
+// permissions granted to each role
+var RolePermissions = map[string][]Permission{
+	RoleAdmin: {
+		EmployeesRead, EmployeesCreate, EmployeesUpdate, EmployeesDelete, EmployeesPurge,
+		EventsRead, EventsWrite,
+		AttendancesRead, AttendancesWrite,
+		AuditRead,
+	},
+	RoleHR: {
+		EmployeesRead, EmployeesCreate, EmployeesUpdate, EmployeesDelete,
+		EventsRead,
+		AttendancesRead,
+		AuditRead,
+	},
+	RoleEventOrganizer: {
+		EmployeesRead,
+		EventsRead, EventsWrite,
+		AttendancesRead, AttendancesWrite,
+	},
+	RoleEmployee: {
+		EmployeesRead, EmployeesUpdateOwn,
+		EventsRead,
+		AttendancesRead, AttendancesWriteOwn,
+	},
+}
+
+// where the employee id a request acts on is taken from, to check :own permissions
+type OwnerSource int
+
+const (
+	//the route has no owner, :own permissions don't apply
+	NoOwner OwnerSource = iota
+	//the path parameter named by OwnerField
+	OwnerPathParam
+	//the field named by OwnerField of the JSON request body
+	OwnerBodyField
+)
+
+// RoutePolicy is the permission a route requires. If the principal lacks it,
+// OwnPermission is enough when the request acts on the principal's own employee id
+type RoutePolicy struct {
+	Permission    Permission
+	OwnPermission Permission
+	Owner         OwnerSource
+	OwnerField    string
+}
+
+// permissions of the routes by method and route template, routes without policy are denied
+var RoutePolicies = map[string]RoutePolicy{
+	"GET /employees":              {Permission: EmployeesRead},
+	"POST /employees":             {Permission: EmployeesCreate},
+	"PUT /employees/:id":          {Permission: EmployeesUpdate, OwnPermission: EmployeesUpdateOwn, Owner: OwnerPathParam, OwnerField: "id"},
+	"PATCH /employees/:id":        {Permission: EmployeesUpdate, OwnPermission: EmployeesUpdateOwn, Owner: OwnerPathParam, OwnerField: "id"},
+	"DELETE /employees/:id":       {Permission: EmployeesDelete},
+	"POST /employees/:id/restore": {Permission: EmployeesDelete},
+	"POST /employees/:id/purge":   {Permission: EmployeesPurge},
+	"GET /employees/:id/events":   {Permission: AttendancesRead},
+
+	"GET /events":        {Permission: EventsRead},
+	"GET /events/:id":    {Permission: EventsRead},
+	"POST /events":       {Permission: EventsWrite},
+	"PUT /events/:id":    {Permission: EventsWrite},
+	"PATCH /events/:id":  {Permission: EventsWrite},
+	"DELETE /events/:id": {Permission: EventsWrite},
+
+	"GET /events/:id/employees": {Permission: AttendancesRead},
+	"POST /events/:id/employees": {Permission: AttendancesWrite, OwnPermission: AttendancesWriteOwn,
+		Owner: OwnerBodyField, OwnerField: "employeeId"},
+	"PATCH /events/:id/employees/:employeeId": {Permission: AttendancesWrite, OwnPermission: AttendancesWriteOwn,
+		Owner: OwnerPathParam, OwnerField: "employeeId"},
+	"DELETE /events/:id/employees/:employeeId": {Permission: AttendancesWrite, OwnPermission: AttendancesWriteOwn,
+		Owner: OwnerPathParam, OwnerField: "employeeId"},
+
+	"GET /audit": {Permission: AuditRead},
+}
+
+// returns true if role is one of Roles
+func ValidRole(role string) bool {
+	_, ok := RolePermissions[role]
+	return ok
+}
+
+// returns true if one of the principal's roles grants permission
+func (p Principal) Can(permission Permission) bool {
+	for _, role := range p.Roles {
+		for _, granted := range RolePermissions[role] {
+			if granted == permission {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -1,46 +1,79 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
-	"os"
+	"log/slog"
+	"time"
 
-	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
 )
 
-func checkErr(err error) {
+//Initialize postgres database, pending migrations are applied if cfg.AutoMigrate is set
+func Init(ctx context.Context, cfg Config) (*sql.DB, error) {
+	db, err := Connect(ctx, cfg)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-}
 
-//Initialize postgres database
-func Init() *sql.DB {
-	//load dotenv file
-	err := godotenv.Load()
-	checkErr(err)
+	if cfg.AutoMigrate {
+		applied, err := MigrateUp(ctx, db)
+		if err != nil {
+			db.Close()
+			return nil, fmt.Errorf("migrating database: %w", err)
+		}
+		slog.Info("applied migrations", "count", len(applied))
+	}
 
-	//database config
-	host := "localhost"
-	port := 5432
-	user := os.Getenv("DBUSER")
-	password := os.Getenv("DBPASS")
-	dbname := os.Getenv("DBNAME")
+	return db, nil
+}
 
+//Connect to the postgres database without migrating it, connecting is retried with backoff
+//as configured by cfg so the database may start after the server
+func Connect(ctx context.Context, cfg Config) (*sql.DB, error) {
 	// Get a database handle.
-	psqlInfo := fmt.Sprintf("host=%s port=%d user=%s "+
-		"password=%s dbname=%s sslmode=disable",
-		host, port, user, password, dbname)
-
-	db, err := sql.Open("postgres", psqlInfo)
-	checkErr(err)
+	db, err := sql.Open("postgres", cfg.DSN())
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
 
 	// Connect to database
-	pingErr := db.Ping()
-	checkErr(pingErr)
+	if err := ping(ctx, db, cfg); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	slog.Info("connected to database")
 
-	fmt.Println("Connected to DB!")
+	return db, nil
+}
 
-	return db
+// pings db until it answers, at most cfg.ConnectRetries times after the first attempt
+func ping(ctx context.Context, db *sql.DB, cfg Config) error {
+	backoff := cfg.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		err := db.PingContext(ctx)
+		if err == nil {
+			return nil
+		}
+		if attempt >= cfg.ConnectRetries {
+			return fmt.Errorf("connecting to database failed after %d attempts: %w", attempt+1, err)
+		}
+
+		slog.Warn("connecting to database failed, retrying", "backoff", backoff.String(), "error", err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > cfg.MaxRetryBackoff {
+			backoff = cfg.MaxRetryBackoff
+		}
+	}
 }
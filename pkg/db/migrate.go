@@ -0,0 +1,228 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// migrations named <version>_<name>.up.sql and <version>_<name>.down.sql
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// key of the advisory lock serializing migrations of concurrently starting instances, "migrate" in ASCII
+const migrationLock int64 = 0x6d696772617465
+
+// Migration is a versioned change of the schema with the SQL to apply and to revert it
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus is a migration with the time it was applied, nil if it is pending
+type MigrationStatus struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// returns the embedded migrations ordered by version
+func Migrations() ([]Migration, error) {
+	files, err := fs.Glob(migrationFiles, "migrations/*.sql")
+	if err != nil {
+		return nil, err
+	}
+	byVersion := make(map[int]*Migration)
+	for _, file := range files {
+		base := path.Base(file)
+		name, direction := strings.TrimSuffix(base, ".sql"), ""
+		switch {
+		case strings.HasSuffix(name, ".up"):
+			name, direction = strings.TrimSuffix(name, ".up"), "up"
+		case strings.HasSuffix(name, ".down"):
+			name, direction = strings.TrimSuffix(name, ".down"), "down"
+		default:
+			return nil, fmt.Errorf("migration %s must end in .up.sql or .down.sql", base)
+		}
+		prefix, name, _ := strings.Cut(name, "_")
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s must start with its version", base)
+		}
+
+		content, err := migrationFiles.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		m := byVersion[version]
+		if m == nil {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		}
+		if direction == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %04d_%s needs both an up and a down file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// creates the table recording the applied migrations
+func createMigrationsTable(ctx context.Context, db *sql.DB) error {
+	_, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`)
+	return err
+}
+
+// applies or reverts m in a transaction together with its row in schema_migrations,
+// returns false if m was applied (up) or not applied (down) already
+func runMigration(ctx context.Context, db *sql.DB, m Migration, up bool) (bool, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	//other instances migrating at the same time wait here and see the migration as applied afterwards
+	if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", migrationLock); err != nil {
+		return false, err
+	}
+	var applied bool
+	row := tx.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM schema_migrations WHERE version = $1)", m.Version)
+	if err := row.Scan(&applied); err != nil {
+		return false, err
+	}
+	if applied == up {
+		return false, nil
+	}
+
+	if up {
+		_, err = tx.ExecContext(ctx, m.Up)
+	} else {
+		_, err = tx.ExecContext(ctx, m.Down)
+	}
+	if err != nil {
+		return false, fmt.Errorf("migration %04d_%s: %w", m.Version, m.Name, err)
+	}
+	if up {
+		_, err = tx.ExecContext(ctx, "INSERT INTO schema_migrations (version, name) VALUES ($1, $2)", m.Version, m.Name)
+	} else {
+		_, err = tx.ExecContext(ctx, "DELETE FROM schema_migrations WHERE version = $1", m.Version)
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// applies all pending migrations in order of their version and returns them
+func MigrateUp(ctx context.Context, db *sql.DB) ([]Migration, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+	if err := createMigrationsTable(ctx, db); err != nil {
+		return nil, err
+	}
+
+	var applied []Migration
+	for _, m := range migrations {
+		ok, err := runMigration(ctx, db, m, true)
+		if err != nil {
+			return applied, err
+		}
+		if ok {
+			applied = append(applied, m)
+		}
+	}
+	return applied, nil
+}
+
+// reverts the last steps applied migrations and returns them
+func MigrateDown(ctx context.Context, db *sql.DB, steps int) ([]Migration, error) {
+	status, err := Status(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	var reverted []Migration
+	for i := len(status) - 1; i >= 0 && len(reverted) < steps; i-- {
+		if status[i].AppliedAt == nil {
+			continue
+		}
+		ok, err := runMigration(ctx, db, status[i].Migration, false)
+		if err != nil {
+			return reverted, err
+		}
+		if ok {
+			reverted = append(reverted, status[i].Migration)
+		}
+	}
+	return reverted, nil
+}
+
+// returns all migrations with the time they were applied. Versions recorded in the database
+// that aren't embedded in the binary are reported as error, the database is newer than the code then
+func Status(ctx context.Context, db *sql.DB) ([]MigrationStatus, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+	if err := createMigrationsTable(ctx, db); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.QueryContext(ctx, "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	appliedAt := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var at time.Time
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		appliedAt[version] = at
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	status := make([]MigrationStatus, len(migrations))
+	for i, m := range migrations {
+		status[i].Migration = m
+		if at, ok := appliedAt[m.Version]; ok {
+			status[i].AppliedAt = &at
+			delete(appliedAt, m.Version)
+		}
+	}
+	for version := range appliedAt {
+		return nil, fmt.Errorf("database has migration %04d applied which is unknown to this binary", version)
+	}
+	return status, nil
+}
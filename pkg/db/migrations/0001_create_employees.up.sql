@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS employees (
+	id SERIAL PRIMARY KEY,
+	first_name VARCHAR(100) NOT NULL,
+	last_name VARCHAR(100) NOT NULL,
+	birthday DATE NOT NULL,
+	gender CHAR(1) NOT NULL CHECK (gender IN ('m', 'f', 'd'))
+);
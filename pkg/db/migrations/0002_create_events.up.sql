@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS events (
+	id SERIAL PRIMARY KEY,
+	name VARCHAR(200) NOT NULL,
+	date DATE NOT NULL
+);
+
+-- events are listed and paged by date
+CREATE INDEX IF NOT EXISTS events_date_id_idx ON events (date, id);
@@ -0,0 +1,19 @@
+-- deleting an event with attendances is refused unless they are deleted first,
+-- deleting an employee withdraws them from all events
+CREATE TABLE IF NOT EXISTS attendances (
+	event_id INTEGER NOT NULL REFERENCES events (id),
+	employee_id INTEGER NOT NULL REFERENCES employees (id) ON DELETE CASCADE,
+	accommodation BOOLEAN NOT NULL DEFAULT false,
+	registered_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	status VARCHAR(20) NOT NULL DEFAULT 'registered' CHECK (status IN ('registered', 'waitlisted', 'cancelled')),
+	PRIMARY KEY (event_id, employee_id)
+);
+
+-- events of an employee are looked up by employee_id
+CREATE INDEX IF NOT EXISTS attendances_employee_id_idx ON attendances (employee_id);
+
+-- databases created before the migrations have the table without the registration columns,
+-- it isn't replaced by CREATE TABLE IF NOT EXISTS so they are added here
+ALTER TABLE attendances
+	ADD COLUMN IF NOT EXISTS registered_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'registered' CHECK (status IN ('registered', 'waitlisted', 'cancelled'));
@@ -0,0 +1,8 @@
+-- keys are random, so a SHA-256 hash is enough and keys can be looked up by it
+CREATE TABLE IF NOT EXISTS api_keys (
+	id SERIAL PRIMARY KEY,
+	name VARCHAR(100) NOT NULL,
+	key_hash CHAR(64) NOT NULL UNIQUE,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	revoked_at TIMESTAMPTZ
+);
@@ -0,0 +1,5 @@
+-- existing keys keep full access, new keys must be given a role
+ALTER TABLE api_keys
+	ADD COLUMN role VARCHAR(20) NOT NULL DEFAULT 'admin' CHECK (role IN ('admin', 'hr', 'event-organizer', 'employee')),
+	ADD COLUMN employee_id INTEGER REFERENCES employees (id) ON DELETE SET NULL;
+ALTER TABLE api_keys ALTER COLUMN role DROP DEFAULT;
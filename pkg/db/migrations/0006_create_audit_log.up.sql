@@ -0,0 +1,15 @@
+-- entries aren't linked to the changed rows, so they are kept when the rows are deleted
+CREATE TABLE IF NOT EXISTS audit_log (
+	id BIGSERIAL PRIMARY KEY,
+	occurred_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	actor VARCHAR(200) NOT NULL,
+	action VARCHAR(20) NOT NULL CHECK (action IN ('create', 'update', 'delete')),
+	entity VARCHAR(20) NOT NULL,
+	entity_id VARCHAR(50) NOT NULL,
+	before JSONB,
+	after JSONB,
+	request_id VARCHAR(128)
+);
+
+-- the history of an entity is looked up by entity and entity_id
+CREATE INDEX IF NOT EXISTS audit_log_entity_idx ON audit_log (entity, entity_id, id);
@@ -0,0 +1,7 @@
+-- employees that were deleted softly are deleted for good
+DELETE FROM employees WHERE deleted_at IS NOT NULL;
+ALTER TABLE employees DROP COLUMN IF EXISTS deleted_at;
+
+DELETE FROM audit_log WHERE action IN ('restore', 'purge');
+ALTER TABLE audit_log DROP CONSTRAINT IF EXISTS audit_log_action_check,
+	ADD CONSTRAINT audit_log_action_check CHECK (action IN ('create', 'update', 'delete'));
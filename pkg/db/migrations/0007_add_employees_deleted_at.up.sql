@@ -0,0 +1,6 @@
+-- deleted employees are kept so past attendances and the audit log still reference them,
+-- purging removes them for good
+ALTER TABLE employees ADD COLUMN deleted_at TIMESTAMPTZ;
+
+ALTER TABLE audit_log DROP CONSTRAINT IF EXISTS audit_log_action_check,
+	ADD CONSTRAINT audit_log_action_check CHECK (action IN ('create', 'update', 'delete', 'restore', 'purge'));
@@ -0,0 +1 @@
+-- the columns are kept, on most databases they were created by 0003 and are dropped with its table
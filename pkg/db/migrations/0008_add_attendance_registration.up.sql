@@ -0,0 +1,5 @@
+-- attendees are returned with their registration time and status. Databases that applied 0003 onto an attendances
+-- table created before the migrations lack these columns, on all others this doesn't change anything
+ALTER TABLE attendances
+	ADD COLUMN IF NOT EXISTS registered_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'registered' CHECK (status IN ('registered', 'waitlisted', 'cancelled'));
@@ -0,0 +1,77 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// tables created by the migrations that the API queries, and schema_migrations the readiness check reads the schema version from
+var Tables = []string{"employees", "events", "attendances", "api_keys", "audit_log", "schema_migrations"}
+
+// MissingTablesError is returned by CheckReady if tables haven't been created, the migrations weren't applied then
+type MissingTablesError struct {
+	Tables []string
+}
+
+func (e *MissingTablesError) Error() string {
+	return fmt.Sprintf("missing tables %s, run migrate up", strings.Join(e.Tables, ", "))
+}
+
+// OutdatedSchemaError is returned by CheckReady if migrations of the binary haven't been applied,
+// the queries of the API may not match the schema then
+type OutdatedSchemaError struct {
+	//highest applied version
+	Version int
+	//newest embedded migration
+	Latest int
+}
+
+func (e *OutdatedSchemaError) Error() string {
+	return fmt.Sprintf("schema is at version %d but migration %d exists, run migrate up", e.Version, e.Latest)
+}
+
+// pings db and checks that all Tables exist and that the newest embedded migration has been applied.
+// A database ahead of the binary is ready, e.g. while a newer version is rolled out
+func CheckReady(ctx context.Context, db *sql.DB) error {
+	if err := db.PingContext(ctx); err != nil {
+		return err
+	}
+
+	rows, err := db.QueryContext(ctx, "SELECT name FROM unnest($1::text[]) AS name WHERE to_regclass(name) IS NULL", pq.Array(Tables))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var missing []string
+	for rows.Next() {
+		var table string
+		if err := rows.Scan(&table); err != nil {
+			return err
+		}
+		missing = append(missing, table)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(missing) > 0 {
+		return &MissingTablesError{Tables: missing}
+	}
+
+	migrations, err := Migrations()
+	if err != nil {
+		return err
+	}
+	var version int
+	if err := db.QueryRowContext(ctx, "SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&version); err != nil {
+		return err
+	}
+	if latest := migrations[len(migrations)-1].Version; version < latest {
+		return &OutdatedSchemaError{Version: version, Latest: latest}
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mtp721/micobo-assignment/pkg/models"
+	"github.com/mtp721/micobo-assignment/pkg/repository"
+)
+
+// registers the employee given in the request body for the event specified by id
+func (h handler) PostAttendance(c *gin.Context) {
+	var attendance models.Attendance
+	eventId, err := paramID(c, "id")
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	fields, err := bindJSON(c, &attendance)
+	if err == nil {
+		err = invalidFields(fields)
+	}
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	attendance.EventID = eventId
+
+	attendance, err = h.Attendances.CreateAttendance(c.Request.Context(), attendance)
+	switch {
+	case errors.Is(err, repository.ErrConflict):
+		err = conflict("employee is already registered for this event")
+	case errors.Is(err, repository.ErrInvalidReference):
+		//event or employee was deleted after the existence check
+		err = notFound("event or employee not found")
+	}
+	if err != nil {
+		writeDBError(c, err, "attendance")
+		return
+	}
+
+	c.IndentedJSON(http.StatusCreated, attendance)
+}
+
+// sets the accommodation flag of an employee registered for an event,
+// the flag is toggled if the request has no body. Bodies without accommodation are rejected with 422
+func (h handler) PatchAttendance(c *gin.Context) {
+	eventId, employeeId, err := attendanceIDs(c)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	var body struct {
+		Accommodation *bool `json:"accommodation" binding:"required"`
+	}
+	data, err := readBody(c)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	//bodies of chunked requests have no content length, so the body itself is checked
+	if len(bytes.TrimSpace(data)) > 0 {
+		fields, err := bindBody(data, &body)
+		if err == nil {
+			err = invalidFields(fields)
+		}
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+	}
+
+	attendance, err := h.Attendances.UpdateAttendance(c.Request.Context(), eventId, employeeId, body.Accommodation)
+	if err != nil {
+		writeDBError(c, err, "attendance")
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, attendance)
+}
+
+// withdraws an employee from an event
+func (h handler) DeleteAttendance(c *gin.Context) {
+	eventId, employeeId, err := attendanceIDs(c)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	attendance, err := h.Attendances.DeleteAttendance(c.Request.Context(), eventId, employeeId)
+	if err != nil {
+		writeDBError(c, err, "attendance")
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, attendance)
+}
+
+// returns the event and employee id of the path parameters id and employeeId
+func attendanceIDs(c *gin.Context) (eventId, employeeId int, err error) {
+	if eventId, err = paramID(c, "id"); err != nil {
+		return 0, 0, err
+	}
+	if employeeId, err = paramID(c, "employeeId"); err != nil {
+		return 0, 0, err
+	}
+	return eventId, employeeId, nil
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mtp721/micobo-assignment/pkg/models"
+	"github.com/mtp721/micobo-assignment/pkg/repository"
+)
+
+// handler of the audit log
+type audit struct {
+	Audit repository.AuditRepository
+}
+
+func NewAudit(auditLog repository.AuditRepository) audit {
+	return audit{Audit: auditLog}
+}
+
+// returns the changes recorded in the audit log in the order they were made,
+// entity=employee, event or attendance and id filter by the changed entity
+func (a audit) GetAudit(c *gin.Context) {
+	filter := repository.AuditFilter{Entity: c.Query("entity"), EntityID: c.Query("id")}
+	if filter.Entity != "" && !contains(repository.AuditEntities, filter.Entity) {
+		writeError(c, badRequest("invalid entity %q, expected one of %s", filter.Entity, strings.Join(repository.AuditEntities, ", ")))
+		return
+	}
+	if filter.EntityID != "" && filter.Entity == "" {
+		writeError(c, badRequest("id requires entity"))
+		return
+	}
+	p, err := parsePage(c)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	if err := writeTotal(c, p, func() (int, error) { return a.Audit.CountAuditEntries(ctx, filter) }); err != nil {
+		writeDBError(c, err, "audit entry")
+		return
+	}
+	entries, err := a.Audit.ListAuditEntries(ctx, filter, p.query())
+	if err != nil {
+		writeDBError(c, err, "audit entry")
+		return
+	}
+
+	entries = paginate(c, p, entries, func(e models.AuditEntry) int { return e.ID })
+	c.IndentedJSON(http.StatusOK, entries)
+}
@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mtp721/micobo-assignment/pkg/auth"
+	"github.com/mtp721/micobo-assignment/pkg/logging"
+	"github.com/mtp721/micobo-assignment/pkg/repository"
+)
+
+// key of the authenticated principal in the gin context
+const principalKey = "principal"
+
+// Authenticate rejects requests without valid API key or JWT with 401,
+// the principal of authenticated requests is available with CurrentPrincipal and their changes are attributed to it in the audit log
+func Authenticate(authenticator *auth.Authenticator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, err := authenticator.Authenticate(c.Request)
+		switch {
+		case errors.Is(err, auth.ErrNoCredentials):
+			c.Header("WWW-Authenticate", `Bearer realm="api"`)
+			writeError(c, newError(http.StatusUnauthorized, "authentication required, send an API key or a bearer token"))
+			return
+		case errors.Is(err, auth.ErrInvalidCredentials):
+			logging.FromContext(c.Request.Context()).Info("authentication failed", "error", err)
+			c.Header("WWW-Authenticate", `Bearer realm="api", error="invalid_token"`)
+			writeError(c, newError(http.StatusUnauthorized, "%s", err.Error()))
+			return
+		case err != nil:
+			writeDBError(c, err, "api key")
+			return
+		}
+
+		c.Set(principalKey, principal)
+		logger := logging.FromContext(c.Request.Context()).With("subject", principal.Subject)
+		ctx := logging.WithLogger(c.Request.Context(), logger)
+		ctx = repository.WithActor(ctx, repository.Actor{Subject: principal.Subject, RequestID: requestID(c)})
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+// returns the principal authenticated by the Authenticate middleware
+func CurrentPrincipal(c *gin.Context) (auth.Principal, bool) {
+	principal, ok := c.Get(principalKey)
+	if !ok {
+		return auth.Principal{}, false
+	}
+	p, ok := principal.(auth.Principal)
+	return p, ok
+}
+
+// Authorize rejects requests with 403 unless the principal's roles grant the permission
+// policies require for the route. Routes without policy are denied
+func Authorize(policies map[string]auth.RoutePolicy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, _ := CurrentPrincipal(c)
+		policy, ok := policies[c.Request.Method+" "+c.FullPath()]
+		if !ok {
+			writeError(c, newError(http.StatusForbidden, "no permission allows %s %s", c.Request.Method, c.FullPath()))
+			return
+		}
+		if principal.Can(policy.Permission) {
+			c.Next()
+			return
+		}
+		if policy.OwnPermission != "" && principal.EmployeeID != 0 && principal.Can(policy.OwnPermission) {
+			if owner, ok := requestOwner(c, policy); ok && owner == principal.EmployeeID {
+				c.Next()
+				return
+			}
+		}
+
+		logging.FromContext(c.Request.Context()).Info("permission denied", "permission", policy.Permission)
+		writeError(c, newError(http.StatusForbidden, "missing permission %s", policy.Permission).withDetails(gin.H{
+			"permission": policy.Permission,
+			"roles":      principal.Roles,
+		}))
+	}
+}
+
+// returns the id of the employee the request acts on as configured by policy,
+// false if the request doesn't name a valid employee id
+func requestOwner(c *gin.Context, policy auth.RoutePolicy) (int, bool) {
+	var value string
+	switch policy.Owner {
+	case auth.OwnerPathParam:
+		value = c.Param(policy.OwnerField)
+	case auth.OwnerBodyField:
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			return 0, false
+		}
+		//the handler binds the body again
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		field, ok := bodyField(body, policy.OwnerField)
+		if !ok {
+			return 0, false
+		}
+		value = string(field)
+	default:
+		return 0, false
+	}
+	id, err := strconv.Atoi(value)
+	return id, err == nil
+}
+
+// returns the value of the field name of the JSON object body, false if body isn't an object
+// or has the field more than once. Keys are matched case-insensitively like the JSON decoder binding the body does,
+// so a duplicate like {"employeeId": 2, "EMPLOYEEID": 7} can't pass the check with a different value than is bound
+func bodyField(body []byte, name string) (json.RawMessage, bool) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return nil, false
+	}
+	var field json.RawMessage
+	found := false
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, false
+		}
+		key, _ := token.(string)
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			return nil, false
+		}
+		if !strings.EqualFold(key, name) {
+			continue
+		}
+		if found {
+			return nil, false
+		}
+		field, found = value, true
+	}
+	return field, found
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mtp721/micobo-assignment/pkg/logging"
+	"github.com/mtp721/micobo-assignment/pkg/models"
+	"github.com/mtp721/micobo-assignment/pkg/repository"
+)
+
+// status of requests the client canceled before the response was written, as used by nginx
+const statusClientClosedRequest = 499
+
+// header carrying the id of a request, it is returned in error responses
+const requestIDHeader = "X-Request-ID"
+
+// error with the status code and message returned to the client
+type apiError struct {
+	status  int
+	message string
+	details any
+}
+
+func (e *apiError) Error() string {
+	return e.message
+}
+
+func newError(status int, format string, a ...any) *apiError {
+	return &apiError{status: status, message: fmt.Sprintf(format, a...)}
+}
+
+func badRequest(format string, a ...any) *apiError {
+	return newError(http.StatusBadRequest, format, a...)
+}
+
+func notFound(format string, a ...any) *apiError {
+	return newError(http.StatusNotFound, format, a...)
+}
+
+func conflict(format string, a ...any) *apiError {
+	return newError(http.StatusConflict, format, a...)
+}
+
+// returns a copy of e with details added to the error response
+func (e *apiError) withDetails(details any) *apiError {
+	withDetails := *e
+	withDetails.details = details
+	return &withDetails
+}
+
+// maps err to the error returned to the client, entity names the resource the failed query was about.
+// Errors that can't be attributed to the request are reported as internal server error
+// so storage internals don't leak to the client
+func mapError(err error, entity string) *apiError {
+	var apiErr *apiError
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+	var notFoundErr *repository.NotFoundError
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return newError(http.StatusGatewayTimeout, "the request timed out")
+	case errors.Is(err, context.Canceled):
+		//the client is gone, the response is only seen in the logs
+		return newError(statusClientClosedRequest, "the request was canceled")
+	case errors.As(err, &notFoundErr):
+		return notFound("%s", notFoundErr.Error())
+	case errors.Is(err, repository.ErrNotFound):
+		return notFound("%s not found", entity)
+	case errors.Is(err, repository.ErrConflict):
+		return conflict("%s already exists", entity)
+	case errors.Is(err, repository.ErrInvalidReference):
+		return newError(http.StatusUnprocessableEntity, "%s references a resource that doesn't exist", entity)
+	case errors.Is(err, repository.ErrInvalidValue):
+		return badRequest("invalid %s value", entity)
+	}
+	return newError(http.StatusInternalServerError, "internal server error")
+}
+
+// returns the id of the request set by the RequestID middleware, or the X-Request-ID header without it
+func requestID(c *gin.Context) string {
+	if id := c.GetString(requestIDKey); id != "" {
+		return id
+	}
+	return c.GetHeader(requestIDHeader)
+}
+
+// writes the error response for err and aborts the request
+func writeError(c *gin.Context, err error) {
+	writeDBError(c, err, "resource")
+}
+
+// writes the error response for err returned by a query about entity and aborts the request
+func writeDBError(c *gin.Context, err error, entity string) {
+	apiErr := mapError(err, entity)
+	if apiErr.status == http.StatusInternalServerError {
+		logging.FromContext(c.Request.Context()).Error("internal error", "error", err)
+	}
+	c.Abort()
+	c.IndentedJSON(apiErr.status, models.Error{
+		Code:      errorCode(apiErr.status),
+		Message:   apiErr.message,
+		Details:   apiErr.details,
+		RequestID: requestID(c),
+	})
+}
+
+// returns the machine readable code of an error response, e.g. not_found for 404
+func errorCode(status int) string {
+	if status == statusClientClosedRequest {
+		return "client_closed_request"
+	}
+	return strings.ToLower(strings.ReplaceAll(http.StatusText(status), " ", "_"))
+}
@@ -1,63 +1,106 @@
 package handlers
 
 import (
-	"database/sql"
-	"fmt"
+	"errors"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
-	_ "github.com/lib/pq"
 	"github.com/mtp721/micobo-assignment/pkg/models"
+	"github.com/mtp721/micobo-assignment/pkg/repository"
 )
 
 type handler struct {
-	DB *sql.DB
+	Employees   repository.EmployeeRepository
+	Events      repository.EventRepository
+	Attendances repository.AttendanceRepository
+	//Now returns the current time, upcoming events are determined relative to it
+	Now func() time.Time
 }
 
-func New(db *sql.DB) handler {
-	return handler{db}
+func New(employees repository.EmployeeRepository, events repository.EventRepository, attendances repository.AttendanceRepository) handler {
+	return handler{Employees: employees, Events: events, Attendances: attendances, Now: time.Now}
 }
 
-// Returns a list of all employees
+// returns the current date of the server's clock
+func (h handler) today() models.Date {
+	return models.DateOf(h.Now())
+}
+
+// returns the id in the path parameter name
+func paramID(c *gin.Context, name string) (int, error) {
+	id, err := strconv.Atoi(c.Param(name))
+	if err != nil {
+		return 0, badRequest("invalid %s %q", name, c.Param(name))
+	}
+	return id, nil
+}
+
+// returns the date in the query parameter name, or the zero date if it isn't set
+func queryDate(c *gin.Context, name string) (models.Date, error) {
+	value := c.Query(name)
+	if value == "" {
+		return models.Date{}, nil
+	}
+	date, err := models.ParseDate(value)
+	if err != nil {
+		return date, badRequest("invalid %s date %q, expected format YYYY-MM-DD", name, value)
+	}
+	return date, nil
+}
+
+// Returns a page of the list of all employees,
+// accepts the query parameters name (case-insensitive substring of first or last name), gender,
+// birthday_from and birthday_to (YYYY-MM-DD) for filtering and sort (e.g. sort=lastName,-birthDay) for ordering.
+// Deleted employees are only returned with include_deleted=true
 func (h handler) GetEmployees(c *gin.Context) {
-	var employees []models.Employee
-	rows, err := h.DB.Query(`SELECT * FROM employees ORDER BY id`)
+	filter := repository.EmployeeFilter{Name: c.Query("name"), Gender: c.Query("gender"), IncludeDeleted: c.Query("include_deleted") == "true"}
+
+	p, err := parsePage(c)
 	if err != nil {
-		c.IndentedJSON(http.StatusNotFound, gin.H{"message": err.Error()})
+		writeError(c, err)
+		return
+	}
+	if filter.Sort, err = parseSort(c.Query("sort"), repository.EmployeeSortFields); err != nil {
+		writeError(c, err)
+		return
+	}
+	if filter.BirthdayFrom, err = queryDate(c, "birthday_from"); err != nil {
+		writeError(c, err)
+		return
+	}
+	if filter.BirthdayTo, err = queryDate(c, "birthday_to"); err != nil {
+		writeError(c, err)
 		return
 	}
 
-	defer rows.Close()
-
-	for rows.Next() {
-		var employee models.Employee
-		if err := rows.Scan(&employee.ID, &employee.FirstName, &employee.LastName, &employee.BirthDay, &employee.Gender); err != nil {
-			c.IndentedJSON(http.StatusNotFound, gin.H{"message": err.Error()})
-			return
-		}
-		employees = append(employees, employee)
+	ctx := c.Request.Context()
+	if err := writeTotal(c, p, func() (int, error) { return h.Employees.CountEmployees(ctx, filter) }); err != nil {
+		writeDBError(c, err, "employee")
+		return
 	}
-	if err := rows.Err(); err != nil {
-		c.IndentedJSON(http.StatusNotFound, gin.H{"message": err.Error()})
+	employees, err := h.Employees.ListEmployees(ctx, filter, p.query())
+	if err != nil {
+		writeDBError(c, err, "employee")
 		return
 	}
 
+	employees = paginate(c, p, employees, func(e models.Employee) int { return e.ID })
 	c.IndentedJSON(http.StatusOK, employees)
 }
 
 // register a new employee
 func (h handler) PostEmployee(c *gin.Context) {
 	var employee models.Employee
-	if err := c.BindJSON(&employee); err != nil {
-		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "binding error: " + err.Error()})
+	if err := h.bindEmployee(c, &employee); err != nil {
+		writeError(c, err)
 		return
 	}
-	fmt.Println(employee)
-	row := h.DB.QueryRow(`INSERT INTO employees (first_name, last_name, birthday, gender) VALUES ($1, $2, $3, $4) RETURNING id`,
-		employee.FirstName, employee.LastName, employee.BirthDay, employee.Gender)
 
-	if err := row.Scan(&employee.ID); err != nil {
-		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+	employee, err := h.Employees.CreateEmployee(c.Request.Context(), employee)
+	if err != nil {
+		writeDBError(c, err, "employee")
 		return
 	}
 
@@ -65,130 +108,330 @@ func (h handler) PostEmployee(c *gin.Context) {
 
 }
 
-// modfiy employee
+// replaces the employee, all fields are required like for new employees
 func (h handler) PutEmployee(c *gin.Context) {
+	id, err := paramID(c, "id")
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
 	var employee models.Employee
-	id := c.Param("id")
+	if err := h.bindEmployee(c, &employee); err != nil {
+		writeError(c, err)
+		return
+	}
+	employee.ID = id
 
-	//Query employee with the specified id and store old values
-	row := h.DB.QueryRow("SELECT * FROM employees WHERE id = $1", id)
-	if err := row.Scan(&employee.ID, &employee.FirstName, &employee.LastName, &employee.BirthDay, &employee.Gender); err != nil {
-		c.IndentedJSON(http.StatusNotFound, gin.H{"message": "Error querying db: " + err.Error()})
+	//Update employee in db
+	employee, err = h.Employees.UpdateEmployee(c.Request.Context(), employee)
+	if err != nil {
+		writeDBError(c, err, "employee")
 		return
 	}
-	//Override values of employee with updated values from request
-	if err := c.BindJSON(&employee); err != nil {
-		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "binding error: " + err.Error()})
+	c.IndentedJSON(http.StatusOK, employee)
+}
+
+// modifies the employee with the JSON merge patch of the request body, fields set to null are removed
+// and have to be valid afterwards like for PUT
+func (h handler) PatchEmployee(c *gin.Context) {
+	id, err := paramID(c, "id")
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	patch, err := readMergePatch(c)
+	if err != nil {
+		writeError(c, err)
 		return
 	}
-	//Update employee in db
-	updRow := h.DB.QueryRow("UPDATE employees SET first_name = $1, last_name = $2, birthday = $3, gender = $4 WHERE id = $5 RETURNING *",
-		employee.FirstName, employee.LastName, employee.BirthDay, employee.Gender, id)
 
-	//Write returned values from db to employee to make sure values were updated correctly
-	if err := updRow.Scan(&employee.ID, &employee.FirstName, &employee.LastName, &employee.BirthDay, &employee.Gender); err != nil {
-		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "Error updating employee: " + err.Error()})
+	//the patch is applied while the employee is locked, so concurrent changes aren't overwritten
+	employee, err := h.Employees.ModifyEmployee(c.Request.Context(), id, func(current models.Employee) (models.Employee, error) {
+		patched, err := applyMergePatch(current, patch)
+		if err != nil {
+			return current, err
+		}
+		var employee models.Employee
+		if err := h.decodeEmployee(patched, &employee); err != nil {
+			return current, err
+		}
+		employee.ID = id
+		return employee, nil
+	})
+	if err != nil {
+		writeDBError(c, err, "employee")
 		return
 	}
 	c.IndentedJSON(http.StatusOK, employee)
 }
 
-// delete employee from db
+// marks the employee as deleted, they are kept for the history of past events until they are purged
 func (h handler) DeleteEmployee(c *gin.Context) {
-	var employee models.Employee
-	id := c.Param("id")
-	row := h.DB.QueryRow("DELETE FROM employees WHERE id = $1 RETURNING *", id)
-	if err := row.Scan(&employee.ID, &employee.FirstName, &employee.LastName, &employee.BirthDay, &employee.Gender); err != nil {
-		c.IndentedJSON(http.StatusNotFound, gin.H{"message": "Error deleting employee: " + err.Error()})
+	id, err := paramID(c, "id")
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	employee, err := h.Employees.DeleteEmployee(c.Request.Context(), id)
+	if err != nil {
+		writeDBError(c, err, "employee")
 		return
 	}
 	c.IndentedJSON(http.StatusOK, employee)
 }
 
-// Returns a list of all events
-func (h handler) GetEvents(c *gin.Context) {
-	var events []models.Event
-	rows, err := h.DB.Query(`SELECT * FROM events ORDER BY id`)
+// restores a deleted employee
+func (h handler) RestoreEmployee(c *gin.Context) {
+	id, err := paramID(c, "id")
 	if err != nil {
-		c.IndentedJSON(http.StatusNotFound, gin.H{"message": err.Error()})
+		writeError(c, err)
 		return
 	}
+	employee, err := h.Employees.RestoreEmployee(c.Request.Context(), id)
+	if err != nil {
+		writeDBError(c, err, "employee")
+		return
+	}
+	c.IndentedJSON(http.StatusOK, employee)
+}
 
-	defer rows.Close()
+// erases an employee with their attendances and their data in the audit log, deleted or not
+func (h handler) PurgeEmployee(c *gin.Context) {
+	id, err := paramID(c, "id")
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	if err := h.Employees.PurgeEmployee(c.Request.Context(), id); err != nil {
+		writeDBError(c, err, "employee")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
 
-	for rows.Next() {
-		var event models.Event
-		if err := rows.Scan(&event.ID, &event.Name, &event.Date); err != nil {
-			c.IndentedJSON(http.StatusNotFound, gin.H{"message": err.Error()})
-			return
-		}
-		events = append(events, event)
+// Returns a page of the list of all upcoming events ordered by date,
+// accepts the query parameters from and to (YYYY-MM-DD) to limit the date range
+// and include_past=true to return events before today as well
+func (h handler) GetEvents(c *gin.Context) {
+	var filter repository.EventFilter
+
+	p, err := parsePage(c)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	if filter.From, err = queryDate(c, "from"); err != nil {
+		writeError(c, err)
+		return
 	}
-	if err := rows.Err(); err != nil {
-		c.IndentedJSON(http.StatusNotFound, gin.H{"message": err.Error()})
+	if filter.To, err = queryDate(c, "to"); err != nil {
+		writeError(c, err)
 		return
 	}
+	if today := h.today(); c.Query("include_past") != "true" && filter.From.Before(today) {
+		filter.From = today
+	}
 
+	ctx := c.Request.Context()
+	if err := writeTotal(c, p, func() (int, error) { return h.Events.CountEvents(ctx, filter) }); err != nil {
+		writeDBError(c, err, "event")
+		return
+	}
+	events, err := h.Events.ListEvents(ctx, filter, p.query())
+	if err != nil {
+		writeDBError(c, err, "event")
+		return
+	}
+
+	events = paginate(c, p, events, func(e models.Event) int { return e.ID })
 	c.IndentedJSON(http.StatusOK, events)
 }
 
 // get event specified by id
 func (h handler) GetEvent(c *gin.Context) {
-	var event models.Event
-	id := c.Param("id")
+	id, err := paramID(c, "id")
+	if err != nil {
+		writeError(c, err)
+		return
+	}
 
 	//Query event with the specified id
-	row := h.DB.QueryRow("SELECT * FROM events WHERE id = $1", id)
-	if err := row.Scan(&event.ID, &event.Name, &event.Date); err != nil {
-		c.IndentedJSON(http.StatusNotFound, gin.H{"message": "Error querying db: " + err.Error()})
+	event, err := h.Events.GetEvent(c.Request.Context(), id)
+	if err != nil {
+		writeDBError(c, err, "event")
 		return
 	}
 
 	c.IndentedJSON(http.StatusOK, event)
 }
 
-/*returns the list of the employees that are attending the event specified by event_id,
-accepts query parameter for filtering if an employee need accommodation or not*/
-func (h handler) GetEmployeesForEvent(c *gin.Context) {
-	var employees []models.Employee
-	eventId := c.Param("id")
-	accommodation := c.Query("accommodation")
+// register a new event
+func (h handler) PostEvent(c *gin.Context) {
+	var event models.Event
+	if err := bindEvent(c, &event); err != nil {
+		writeError(c, err)
+		return
+	}
 
-	var accommodationQuery string
-	switch accommodation {
-	case "true":
-		accommodationQuery = "AND accommodation = true"
-	case "false":
-		accommodationQuery = "AND accommodation = false"
-	default:
-		accommodationQuery = ""
+	event, err := h.Events.CreateEvent(c.Request.Context(), event)
+	if err != nil {
+		writeDBError(c, err, "event")
+		return
 	}
 
-	query := fmt.Sprintf(`SELECT id, first_name, last_name, birthday, gender FROM employees JOIN attendances 
-		ON attendances.employee_id = employees.id WHERE attendances.event_id = $1 %s ORDER BY id`, accommodationQuery)
+	c.IndentedJSON(http.StatusCreated, event)
+}
+
+// replaces the event, name and date are required like for new events
+func (h handler) PutEvent(c *gin.Context) {
+	id, err := paramID(c, "id")
+	if err != nil {
+		writeError(c, err)
+		return
+	}
 
-	rows, err := h.DB.Query(query, eventId)
+	var event models.Event
+	if err := bindEvent(c, &event); err != nil {
+		writeError(c, err)
+		return
+	}
+	event.ID = id
 
+	//Update event in db
+	event, err = h.Events.UpdateEvent(c.Request.Context(), event)
 	if err != nil {
-		c.IndentedJSON(http.StatusNotFound, gin.H{"message": err.Error()})
+		writeDBError(c, err, "event")
 		return
 	}
+	c.IndentedJSON(http.StatusOK, event)
+}
 
-	defer rows.Close()
+// modifies the event with the JSON merge patch of the request body
+func (h handler) PatchEvent(c *gin.Context) {
+	id, err := paramID(c, "id")
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	patch, err := readMergePatch(c)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
 
-	for rows.Next() {
-		var employee models.Employee
-		if err := rows.Scan(&employee.ID, &employee.FirstName, &employee.LastName, &employee.BirthDay, &employee.Gender); err != nil {
-			c.IndentedJSON(http.StatusNotFound, gin.H{"message": err.Error()})
-			return
+	//the patch is applied while the event is locked, so concurrent changes aren't overwritten
+	event, err := h.Events.ModifyEvent(c.Request.Context(), id, func(current models.Event) (models.Event, error) {
+		patched, err := applyMergePatch(current, patch)
+		if err != nil {
+			return current, err
+		}
+		var event models.Event
+		if err := decodeEvent(patched, &event); err != nil {
+			return current, err
 		}
-		employees = append(employees, employee)
+		event.ID = id
+		return event, nil
+	})
+	if err != nil {
+		writeDBError(c, err, "event")
+		return
 	}
-	if err := rows.Err(); err != nil {
-		c.IndentedJSON(http.StatusNotFound, gin.H{"message": err.Error()})
+	c.IndentedJSON(http.StatusOK, event)
+}
+
+// delete event from db, attendances of the event are deleted as well if the query parameter cascade=true is set,
+// otherwise the request is refused with 409 as long as employees that aren't deleted are registered for the event
+func (h handler) DeleteEvent(c *gin.Context) {
+	id, err := paramID(c, "id")
+	if err != nil {
+		writeError(c, err)
 		return
 	}
 
-	c.IndentedJSON(http.StatusOK, employees)
+	event, err := h.Events.DeleteEvent(c.Request.Context(), id, c.Query("cascade") == "true")
+	var hasAttendees *repository.HasAttendeesError
+	if errors.As(err, &hasAttendees) {
+		err = conflict("event has %d registered employees, use cascade=true to delete them as well", hasAttendees.Attendees)
+	}
+	if err != nil {
+		writeDBError(c, err, "event")
+		return
+	}
+	c.IndentedJSON(http.StatusOK, event)
+}
+
+/*returns a page of the list of the employees that are attending the event specified by event_id together with their
+accommodation flag, registration time and status,
+accepts query parameter for filtering if an employee need accommodation or not,
+deleted employees are only returned with include_deleted=true*/
+func (h handler) GetEmployeesForEvent(c *gin.Context) {
+	filter := repository.AttendeeFilter{IncludeDeleted: c.Query("include_deleted") == "true"}
+	eventId, err := paramID(c, "id")
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	p, err := parsePage(c)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	switch c.Query("accommodation") {
+	case "true":
+		accommodation := true
+		filter.Accommodation = &accommodation
+	case "false":
+		accommodation := false
+		filter.Accommodation = &accommodation
+	}
+
+	ctx := c.Request.Context()
+	if err := writeTotal(c, p, func() (int, error) { return h.Attendances.CountAttendees(ctx, eventId, filter) }); err != nil {
+		writeDBError(c, err, "attendance")
+		return
+	}
+	attendees, err := h.Attendances.ListAttendees(ctx, eventId, filter, p.query())
+	if err != nil {
+		writeDBError(c, err, "attendance")
+		return
+	}
+
+	attendees = paginate(c, p, attendees, func(a models.EventAttendee) int { return a.ID })
+	c.IndentedJSON(http.StatusOK, attendees)
 
 }
+
+// returns the list of events the employee specified by id is registered for together with the registration metadata,
+// accepts the query parameters upcoming=true or past=true to only return events on or after, or before today.
+// Unknown employees are reported with 404
+func (h handler) GetEventsForEmployee(c *gin.Context) {
+	var filter repository.EventFilter
+	employeeId, err := paramID(c, "id")
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	upcoming := c.Query("upcoming") == "true"
+	past := c.Query("past") == "true"
+
+	switch {
+	case upcoming && past:
+		writeError(c, badRequest("upcoming and past can't be combined"))
+		return
+	case upcoming:
+		filter.From = h.today()
+	case past:
+		filter.Before = h.today()
+	}
+
+	events, err := h.Attendances.ListEmployeeEvents(c.Request.Context(), employeeId, filter)
+	if err != nil {
+		writeDBError(c, err, "attendance")
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, events)
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mtp721/micobo-assignment/pkg/db"
+	"github.com/mtp721/micobo-assignment/pkg/logging"
+)
+
+// status reported by the health endpoints
+const (
+	statusOK          = "ok"
+	statusUnavailable = "unavailable"
+)
+
+// handlers of the health and readiness probes
+type health struct {
+	//DB is nil if the API doesn't use a database, e.g. with the in-memory store
+	DB *sql.DB
+	//Timeout of the database checks of the readiness probe
+	Timeout time.Duration
+}
+
+func NewHealth(db *sql.DB, timeout time.Duration) health {
+	return health{DB: db, Timeout: timeout}
+}
+
+// connection pool statistics of sql.DBStats
+type poolStats struct {
+	MaxOpenConnections int    `json:"maxOpenConnections"`
+	OpenConnections    int    `json:"openConnections"`
+	InUse              int    `json:"inUse"`
+	Idle               int    `json:"idle"`
+	WaitCount          int64  `json:"waitCount"`
+	WaitDuration       string `json:"waitDuration"`
+	MaxIdleClosed      int64  `json:"maxIdleClosed"`
+	MaxIdleTimeClosed  int64  `json:"maxIdleTimeClosed"`
+	MaxLifetimeClosed  int64  `json:"maxLifetimeClosed"`
+}
+
+type databaseStatus struct {
+	Status        string   `json:"status"`
+	Error         string   `json:"error,omitempty"`
+	MissingTables []string `json:"missingTables,omitempty"`
+	//applied and newest known migration, only reported if the schema is outdated
+	SchemaVersion         int       `json:"schemaVersion,omitempty"`
+	ExpectedSchemaVersion int       `json:"expectedSchemaVersion,omitempty"`
+	Pool                  poolStats `json:"pool"`
+}
+
+type readiness struct {
+	Status   string          `json:"status"`
+	Database *databaseStatus `json:"database,omitempty"`
+}
+
+// reports that the process is up, it doesn't check any dependencies
+func (h health) Healthz(c *gin.Context) {
+	c.IndentedJSON(http.StatusOK, gin.H{"status": statusOK})
+}
+
+// reports if the API can serve requests, returns 503 if the database is unreachable,
+// its tables are missing or migrations are pending. The response includes the connection pool statistics
+func (h health) Readyz(c *gin.Context) {
+	if h.DB == nil {
+		c.IndentedJSON(http.StatusOK, readiness{Status: statusOK})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.Timeout)
+	defer cancel()
+	database := databaseStatus{Status: statusOK}
+	err := db.CheckReady(ctx, h.DB)
+
+	var missing *db.MissingTablesError
+	var outdated *db.OutdatedSchemaError
+	switch {
+	case err == nil:
+	case errors.As(err, &missing):
+		database.Error = "tables are missing, the migrations haven't been applied"
+		database.MissingTables = missing.Tables
+	case errors.As(err, &outdated):
+		database.Error = "the schema is outdated, pending migrations haven't been applied"
+		database.SchemaVersion = outdated.Version
+		database.ExpectedSchemaVersion = outdated.Latest
+	case errors.Is(err, context.DeadlineExceeded):
+		database.Error = "database didn't answer within " + h.Timeout.String()
+	default:
+		//the error isn't returned as it may contain connection details
+		logging.FromContext(ctx).Warn("readiness check failed", "error", err)
+		database.Error = "database is unreachable"
+	}
+
+	stats := h.DB.Stats()
+	database.Pool = poolStats{
+		MaxOpenConnections: stats.MaxOpenConnections,
+		OpenConnections:    stats.OpenConnections,
+		InUse:              stats.InUse,
+		Idle:               stats.Idle,
+		WaitCount:          stats.WaitCount,
+		WaitDuration:       stats.WaitDuration.String(),
+		MaxIdleClosed:      stats.MaxIdleClosed,
+		MaxIdleTimeClosed:  stats.MaxIdleTimeClosed,
+		MaxLifetimeClosed:  stats.MaxLifetimeClosed,
+	}
+
+	status := http.StatusOK
+	resp := readiness{Status: statusOK, Database: &database}
+	if database.Error != "" {
+		status = http.StatusServiceUnavailable
+		resp.Status, database.Status = statusUnavailable, statusUnavailable
+	}
+	c.IndentedJSON(status, resp)
+}
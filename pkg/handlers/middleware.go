@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mtp721/micobo-assignment/pkg/logging"
+	"github.com/mtp721/micobo-assignment/pkg/repository"
+)
+
+// key of the request id in the gin context
+const requestIDKey = "requestID"
+
+// actor changes are attributed to in the audit log if the request isn't authenticated
+const anonymousActor = "anonymous"
+
+// maximum length of request ids accepted from clients, longer ids are replaced
+const maxRequestIDLength = 128
+
+// returns a random request id of 32 hex digits
+func newRequestID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// returns true if id can be used as request id, it is echoed in headers and logs
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range id {
+		if r < '!' || r > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// RequestID takes the request id from the X-Request-ID header or generates one, returns it in the response header
+// and adds a logger with the id to the request context, so every log line of the request carries it.
+// The id is also added to the audit actor of the request context
+func RequestID(logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(requestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		c.Set(requestIDKey, id)
+		c.Header(requestIDHeader, id)
+		ctx := logging.WithLogger(c.Request.Context(), logger.With("request_id", id))
+		ctx = repository.WithActor(ctx, repository.Actor{Subject: anonymousActor, RequestID: id})
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+// returns the query of u with the values of sensitive parameters like name redacted
+func redactQuery(u *url.URL) string {
+	query := u.Query()
+	for key := range query {
+		if logging.IsSensitive(key) {
+			query[key] = []string{logging.Redacted}
+		}
+	}
+	return query.Encode()
+}
+
+// AccessLog logs every request after it was handled with its route, status and latency
+func AccessLog() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		if status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		logging.FromContext(c.Request.Context()).LogAttrs(c.Request.Context(), level, "request",
+			slog.String("method", c.Request.Method),
+			slog.String("route", c.FullPath()),
+			slog.String("path", c.Request.URL.Path),
+			slog.String("query", redactQuery(c.Request.URL)),
+			slog.Int("status", status),
+			slog.Duration("latency", time.Since(start)),
+			slog.Int("bytes", c.Writer.Size()),
+			slog.String("client_ip", c.ClientIP()),
+		)
+	}
+}
+
+// Recovery logs panics of handlers and responds with 500
+func Recovery() gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(nil, func(c *gin.Context, err any) {
+		logging.FromContext(c.Request.Context()).Error("panic", "error", err)
+		writeError(c, newError(http.StatusInternalServerError, "internal server error"))
+	})
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mtp721/micobo-assignment/pkg/repository"
+)
+
+// number of rows returned by list endpoints if no limit is given, and the highest accepted limit
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 500
+)
+
+// pagination parameters of a list request, rows are paged by keyset on their id
+type page struct {
+	limit int
+	//id of the last row of the previous page, 0 for the first page
+	cursor       int
+	includeTotal bool
+}
+
+// reads the query parameters limit, cursor and include_total of a list request
+func parsePage(c *gin.Context) (page, error) {
+	p := page{limit: defaultPageLimit, includeTotal: c.Query("include_total") == "true"}
+	if limit := c.Query("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > maxPageLimit {
+			return p, badRequest("invalid limit %q, expected a number between 1 and %d", limit, maxPageLimit)
+		}
+		p.limit = n
+	}
+	if cursor := c.Query("cursor"); cursor != "" {
+		n, err := strconv.Atoi(cursor)
+		if err != nil || n < 1 {
+			return p, badRequest("invalid cursor %q", cursor)
+		}
+		p.cursor = n
+	}
+	return p, nil
+}
+
+// returns the page to query from the repository, one extra row is fetched to find out if there is a next page
+func (p page) query() repository.Page {
+	return repository.Page{Limit: p.limit + 1, Cursor: p.cursor}
+}
+
+// drops the extra row fetched by page.query and, if there is a next page,
+// sets the X-Next-Cursor header and a Link header pointing to it
+func paginate[T any](c *gin.Context, p page, rows []T, id func(T) int) []T {
+	if len(rows) <= p.limit {
+		return rows
+	}
+	rows = rows[:p.limit]
+	next := strconv.Itoa(id(rows[len(rows)-1]))
+
+	u := *c.Request.URL
+	q := u.Query()
+	q.Set("cursor", next)
+	u.RawQuery = q.Encode()
+
+	c.Header("X-Next-Cursor", next)
+	c.Header("Link", fmt.Sprintf(`<%s>; rel="next"`, u.String()))
+	return rows
+}
+
+// counts the rows if the total was requested and sets the X-Total-Count header
+func writeTotal(c *gin.Context, p page, count func() (int, error)) error {
+	if !p.includeTotal {
+		return nil
+	}
+	total, err := count()
+	if err != nil {
+		return err
+	}
+	c.Header("X-Total-Count", strconv.Itoa(total))
+	return nil
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// returned by decodeJSON for documents followed by more data
+var errTrailingData = errors.New("unexpected data after the JSON document")
+
+// media type of the RFC 7396 JSON merge patches accepted by the PATCH routes of employees and events
+const mergePatchType = "application/merge-patch+json"
+
+// returns the merge patch of the request body, requests with other content types are refused with 415
+func readMergePatch(c *gin.Context) ([]byte, error) {
+	if c.ContentType() != mergePatchType {
+		return nil, newError(http.StatusUnsupportedMediaType, "content type must be %s", mergePatchType)
+	}
+	patch, err := readBody(c)
+	if err != nil {
+		return nil, err
+	}
+	//malformed patches are refused before the resource is queried
+	if _, err := decodeJSON(patch); err != nil {
+		return nil, bodyError(err)
+	}
+	return patch, nil
+}
+
+// returns the JSON of current with patch applied, it is bound and validated like the body of a PUT request
+func applyMergePatch(current any, patch []byte) ([]byte, error) {
+	target, err := json.Marshal(current)
+	if err != nil {
+		return nil, err
+	}
+	patched, err := mergePatch(target, patch)
+	if err != nil {
+		return nil, bodyError(err)
+	}
+	return patched, nil
+}
+
+// returns the JSON document target with the merge patch applied as defined by RFC 7396
+func mergePatch(target, patch []byte) ([]byte, error) {
+	targetValue, err := decodeJSON(target)
+	if err != nil {
+		return nil, err
+	}
+	patchValue, err := decodeJSON(patch)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(mergeValue(targetValue, patchValue))
+}
+
+// decodes the JSON document data, numbers are kept as json.Number so they aren't rounded
+func decodeJSON(data []byte) (any, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	if decoder.More() {
+		return nil, errTrailingData
+	}
+	return value, nil
+}
+
+// merges patch into target: members of a patch object replace the members of target, null removes them
+// and objects are merged recursively. Patches that aren't objects replace target
+func mergeValue(target, patch any) any {
+	patchObject, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	targetObject, ok := target.(map[string]any)
+	if !ok {
+		targetObject = map[string]any{}
+	}
+	for name, value := range patchObject {
+		if value == nil {
+			delete(targetObject, name)
+			continue
+		}
+		targetObject[name] = mergeValue(targetObject[name], value)
+	}
+	return targetObject
+}
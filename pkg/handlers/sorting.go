@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mtp721/micobo-assignment/pkg/repository"
+)
+
+// parses a sort parameter like "lastName,-birthDay" into sort keys, a leading - sorts descending.
+// Only the fields in allowed are accepted, id is appended as last key so the order is unique
+// and can be used for keyset pagination
+func parseSort(param string, allowed []string) ([]repository.SortKey, error) {
+	var keys []repository.SortKey
+	hasId := false
+	for _, field := range strings.Split(param, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		key := repository.SortKey{Field: strings.TrimPrefix(field, "-"), Desc: strings.HasPrefix(field, "-")}
+		if !contains(allowed, key.Field) {
+			sorted := append([]string(nil), allowed...)
+			sort.Strings(sorted)
+			return nil, badRequest("unknown sort field %q, allowed fields: %s", field, strings.Join(sorted, ", ")).
+				withDetails(gin.H{"allowed": sorted})
+		}
+		hasId = hasId || key.Field == "id"
+		keys = append(keys, key)
+	}
+	if !hasId {
+		keys = append(keys, repository.SortKey{Field: "id"})
+	}
+	return keys, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/mtp721/micobo-assignment/pkg/models"
+)
+
+func init() {
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		//report fields by their json name
+		v.RegisterTagNameFunc(func(field reflect.StructField) string {
+			name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+			if name == "-" {
+				return ""
+			}
+			return name
+		})
+		v.RegisterValidation("notblank", func(fl validator.FieldLevel) bool {
+			return strings.TrimSpace(fl.Field().String()) != ""
+		})
+		//dates are validated as their YYYY-MM-DD string, the zero date as empty string
+		v.RegisterCustomTypeFunc(func(field reflect.Value) any {
+			if date := field.Interface().(models.Date); !date.IsZero() {
+				return date.String()
+			}
+			return ""
+		}, models.Date{})
+	}
+}
+
+// returns the request body
+func readBody(c *gin.Context) ([]byte, error) {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return nil, badRequest("request body could not be read")
+	}
+	return body, nil
+}
+
+// binds the JSON request body to obj like bindBody
+func bindJSON(c *gin.Context, obj any) ([]models.FieldError, error) {
+	body, err := readBody(c)
+	if err != nil {
+		return nil, err
+	}
+	return bindBody(body, obj)
+}
+
+// binds the JSON body to obj, malformed bodies are returned as error
+// and invalid dates, values of the wrong type and the fields failing the binding rules of obj as field errors
+func bindBody(body []byte, obj any) ([]models.FieldError, error) {
+	err := binding.JSON.BindBody(body, obj)
+	if err != nil {
+		if fields := dateFieldErrors(body, obj); len(fields) > 0 {
+			return fields, nil
+		}
+	}
+	var validationErrs validator.ValidationErrors
+	if errors.As(err, &validationErrs) {
+		fields := make([]models.FieldError, len(validationErrs))
+		for i, fieldErr := range validationErrs {
+			fields[i] = models.FieldError{Field: fieldErr.Field(), Message: validationMessage(fieldErr)}
+		}
+		return fields, nil
+	}
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		return []models.FieldError{{Field: typeErr.Field, Message: "must be " + jsonType(typeErr.Type)}}, nil
+	}
+	if err != nil {
+		return nil, bodyError(err)
+	}
+	return nil, nil
+}
+
+// returns the 400 error for a request body that can't be decoded.
+// The messages of the decoder name Go types, so they aren't passed on to the client
+func bodyError(err error) error {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.Is(err, io.EOF):
+		return badRequest("request body is empty")
+	case errors.As(err, &syntaxErr), errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, errTrailingData):
+		return badRequest("malformed JSON")
+	case errors.As(err, &typeErr):
+		return badRequest("request body must be %s", jsonType(typeErr.Type))
+	}
+	return badRequest("invalid request body")
+}
+
+// returns the JSON type values of typ are decoded from, with its article
+func jsonType(typ reflect.Type) string {
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	switch typ.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Struct, reflect.Map:
+		return "an object"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	}
+	return "a valid value"
+}
+
+// returns the message for a field failing a binding rule
+func validationMessage(fieldErr validator.FieldError) string {
+	switch fieldErr.Tag() {
+	case "required":
+		return "is required"
+	case "notblank":
+		return "must not be blank"
+	case "max":
+		return "must be at most " + fieldErr.Param() + " characters"
+	case "oneof":
+		return "must be one of " + strings.ReplaceAll(fieldErr.Param(), " ", ", ")
+	}
+	return "is invalid"
+}
+
+// returns errors for the date fields of the struct obj points to that can't be decoded from body.
+// The JSON decoder stops at the first invalid date without naming the field, so they are checked one by one
+func dateFieldErrors(body []byte, obj any) []models.FieldError {
+	var values map[string]json.RawMessage
+	if err := json.Unmarshal(body, &values); err != nil {
+		return nil
+	}
+	var fields []models.FieldError
+	typ := reflect.TypeOf(obj).Elem()
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.Type != reflect.TypeOf(models.Date{}) {
+			continue
+		}
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		for key, value := range values {
+			//the JSON decoder matches keys case-insensitively as well
+			var date models.Date
+			if strings.EqualFold(key, name) && json.Unmarshal(value, &date) != nil {
+				fields = append(fields, models.FieldError{Field: name, Message: "must be a date in the format YYYY-MM-DD"})
+			}
+		}
+	}
+	return fields
+}
+
+// returns a 422 error listing the invalid fields, or nil if there are none
+func invalidFields(fields []models.FieldError) error {
+	if len(fields) == 0 {
+		return nil
+	}
+	names := make([]string, len(fields))
+	for i, field := range fields {
+		names[i] = field.Field
+	}
+	return newError(http.StatusUnprocessableEntity, "invalid fields: %s", strings.Join(names, ", ")).withDetails(fields)
+}
+
+// binds the JSON request body to employee and validates it like decodeEmployee
+func (h handler) bindEmployee(c *gin.Context, employee *models.Employee) error {
+	body, err := readBody(c)
+	if err != nil {
+		return err
+	}
+	return h.decodeEmployee(body, employee)
+}
+
+// binds the JSON body to employee and validates it, birthdays must not be in the future
+func (h handler) decodeEmployee(body []byte, employee *models.Employee) error {
+	fields, err := bindBody(body, employee)
+	if err != nil {
+		return err
+	}
+	if employee.BirthDay.After(h.today()) {
+		fields = append(fields, models.FieldError{Field: "birthDay", Message: "must not be in the future"})
+	}
+	return invalidFields(fields)
+}
+
+// binds the JSON request body to event and validates it
+func bindEvent(c *gin.Context, event *models.Event) error {
+	body, err := readBody(c)
+	if err != nil {
+		return err
+	}
+	return decodeEvent(body, event)
+}
+
+// binds the JSON body to event and validates it
+func decodeEvent(body []byte, event *models.Event) error {
+	fields, err := bindBody(body, event)
+	if err != nil {
+		return err
+	}
+	return invalidFields(fields)
+}
@@ -0,0 +1,63 @@
+// Package logging sets up structured JSON logging with log/slog and redacts personal data from log lines.
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// replaces the values of redacted attributes
+const Redacted = "[REDACTED]"
+
+// keys of attributes that may carry personal data or secrets, compared case-insensitively.
+// Field names of the JSON API are used, so attributes named after request fields are covered as well
+var sensitiveKeys = map[string]bool{
+	"firstname":     true,
+	"first_name":    true,
+	"lastname":      true,
+	"last_name":     true,
+	"name":          true,
+	"birthday":      true,
+	"birthday_from": true,
+	"birthday_to":   true,
+	"gender":        true,
+	"password":      true,
+	"authorization": true,
+	"token":         true,
+	"api_key":       true,
+}
+
+// returns true if values of attributes named key are redacted
+func IsSensitive(key string) bool {
+	return sensitiveKeys[strings.ToLower(key)]
+}
+
+// redacts sensitive attributes, also inside groups
+func redact(groups []string, attr slog.Attr) slog.Attr {
+	if IsSensitive(attr.Key) && attr.Value.Kind() != slog.KindGroup {
+		return slog.String(attr.Key, Redacted)
+	}
+	return attr
+}
+
+// returns a logger writing JSON lines with sensitive attributes redacted
+func New(w io.Writer, level slog.Leveler) *slog.Logger {
+	return slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level, ReplaceAttr: redact}))
+}
+
+type loggerKey struct{}
+
+// returns a copy of ctx carrying logger
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// returns the logger of ctx, e.g. the logger of a request with its id, or the default logger
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
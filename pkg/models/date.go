@@ -0,0 +1,111 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// format of dates on the wire, YYYY-MM-DD
+const DateLayout = "2006-01-02"
+
+// civil date without time of day or time zone, e.g. a birthday.
+// It is encoded as YYYY-MM-DD in JSON and stored in postgres date columns
+type Date struct {
+	Year  int
+	Month time.Month
+	Day   int
+}
+
+// returns the date of t in t's location
+func DateOf(t time.Time) Date {
+	year, month, day := t.Date()
+	return Date{year, month, day}
+}
+
+// parses a date in the format YYYY-MM-DD
+func ParseDate(s string) (Date, error) {
+	t, err := time.Parse(DateLayout, s)
+	if err != nil {
+		return Date{}, err
+	}
+	return DateOf(t), nil
+}
+
+// returns the date formatted as YYYY-MM-DD
+func (d Date) String() string {
+	return fmt.Sprintf("%04d-%02d-%02d", d.Year, d.Month, d.Day)
+}
+
+func (d Date) IsZero() bool {
+	return d == Date{}
+}
+
+// returns midnight UTC of the date
+func (d Date) time() time.Time {
+	return time.Date(d.Year, d.Month, d.Day, 0, 0, 0, 0, time.UTC)
+}
+
+func (d Date) Before(other Date) bool {
+	return d.time().Before(other.time())
+}
+
+func (d Date) After(other Date) bool {
+	return d.time().After(other.time())
+}
+
+func (d Date) MarshalJSON() ([]byte, error) {
+	if d.IsZero() {
+		return []byte("null"), nil
+	}
+	return json.Marshal(d.String())
+}
+
+// decodes a YYYY-MM-DD string, used for JSON strings
+func (d *Date) UnmarshalText(text []byte) error {
+	date, err := ParseDate(string(text))
+	if err != nil {
+		return fmt.Errorf("invalid date %q, expected format YYYY-MM-DD", text)
+	}
+	*d = date
+	return nil
+}
+
+// implements sql.Scanner for date columns, lib/pq returns them as time.Time
+func (d *Date) Scan(src any) error {
+	switch src := src.(type) {
+	case nil:
+		*d = Date{}
+		return nil
+	case time.Time:
+		*d = DateOf(src)
+		return nil
+	case string:
+		return d.scanString(src)
+	case []byte:
+		return d.scanString(string(src))
+	}
+	return fmt.Errorf("can't scan %T into Date", src)
+}
+
+func (d *Date) scanString(src string) error {
+	//date columns may be returned as timestamp text, only the date part is used
+	if len(src) > len(DateLayout) {
+		src = src[:len(DateLayout)]
+	}
+	date, err := ParseDate(src)
+	if err != nil {
+		return err
+	}
+	*d = date
+	return nil
+}
+
+// implements driver.Valuer, the date is sent as YYYY-MM-DD so postgres doesn't apply time zones
+func (d Date) Value() (driver.Value, error) {
+	if d.IsZero() {
+		return nil, nil
+	}
+	return d.String(), nil
+}
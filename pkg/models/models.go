@@ -1,15 +1,108 @@
 package models
 
+import (
+	"encoding/json"
+	"log/slog"
+	"time"
+)
+
+// gender is m (male), f (female) or d (diverse)
 type Employee struct {
 	ID        int    `json:"id"`
-	FirstName string `json:"firstName"`
-	LastName  string `json:"lastName"`
-	BirthDay  string `json:"birthDay"`
-	Gender    string `json:"gender"`
+	FirstName string `json:"firstName" binding:"required,notblank,max=100"`
+	LastName  string `json:"lastName" binding:"required,notblank,max=100"`
+	BirthDay  Date   `json:"birthDay" binding:"required"`
+	Gender    string `json:"gender" binding:"required,oneof=m f d"`
+	//time the employee was deleted, deleted employees are hidden unless include_deleted is set
+	DeletedAt *time.Time `json:"deletedAt,omitempty"`
+}
+
+// implements slog.LogValuer, only the id is logged so personal data doesn't end up in the logs
+func (e Employee) LogValue() slog.Value {
+	return slog.GroupValue(slog.Int("id", e.ID))
 }
 
 type Event struct {
+	ID   int    `json:"id"`
+	Name string `json:"name" binding:"required,notblank,max=200"`
+	Date Date   `json:"date" binding:"required"`
+}
+
+// status of an employee's registration for an event
+const (
+	AttendanceRegistered = "registered"
+	AttendanceWaitlisted = "waitlisted"
+	AttendanceCancelled  = "cancelled"
+)
+
+type Attendance struct {
+	EventID       int       `json:"eventId"`
+	EmployeeID    int       `json:"employeeId" binding:"required"`
+	Accommodation bool      `json:"accommodation"`
+	RegisteredAt  time.Time `json:"registeredAt"`
+	Status        string    `json:"status"`
+}
+
+// employee attending an event together with the metadata of their registration
+type EventAttendee struct {
+	Employee
+	Accommodation bool      `json:"accommodation"`
+	RegisteredAt  time.Time `json:"registeredAt"`
+	Status        string    `json:"status"`
+}
+
+// event an employee is registered for together with the metadata of their registration
+type EmployeeEvent struct {
+	Event
+	Accommodation bool      `json:"accommodation"`
+	RegisteredAt  time.Time `json:"registeredAt"`
+	Status        string    `json:"status"`
+}
+
+// body of all error responses
+type Error struct {
+	//machine readable error code, e.g. not_found
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	Details   any    `json:"details,omitempty"`
+	RequestID string `json:"requestId,omitempty"`
+}
+
+// field of a request body that failed validation, returned in the details of 422 errors
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// API key a client authenticates with, only the hash of the key is stored
+type APIKey struct {
 	ID   int    `json:"id"`
 	Name string `json:"name"`
-	Date string `json:"date"`
+	//hex encoded SHA-256 of the key
+	Hash string `json:"-"`
+	//role granting the key's permissions
+	Role string `json:"role"`
+	//employee the key belongs to, nil for keys of services
+	EmployeeID *int       `json:"employeeId,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty"`
+}
+
+// change to an employee, event or attendance recorded in the audit log
+type AuditEntry struct {
+	ID         int       `json:"id"`
+	OccurredAt time.Time `json:"occurredAt"`
+	//subject of the principal that made the change
+	Actor string `json:"actor"`
+	//create, update, delete, restore or purge
+	Action string `json:"action"`
+	//employee, event or attendance
+	Entity string `json:"entity"`
+	//id of the entity, attendances are identified by eventId/employeeId
+	EntityID string `json:"entityId"`
+	//fields of the entity before and after the change, updates only have the changed fields.
+	//Before is null for created and after for deleted entities
+	Before    map[string]json.RawMessage `json:"before"`
+	After     map[string]json.RawMessage `json:"after"`
+	RequestID string                     `json:"requestId,omitempty"`
 }
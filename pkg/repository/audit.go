@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/mtp721/micobo-assignment/pkg/models"
+)
+
+// entities changes are recorded for in the audit log
+const (
+	EntityEmployee   = "employee"
+	EntityEvent      = "event"
+	EntityAttendance = "attendance"
+)
+
+// AuditEntities lists the entities of the audit log
+var AuditEntities = []string{EntityEmployee, EntityEvent, EntityAttendance}
+
+// actions recorded in the audit log
+const (
+	ActionCreate = "create"
+	ActionUpdate = "update"
+	ActionDelete = "delete"
+	//a deleted employee was restored
+	ActionRestore = "restore"
+	//an employee was erased, their data is removed from earlier entries
+	ActionPurge = "purge"
+)
+
+// Actor made the changes of a request, repositories record it in the audit log with every change
+type Actor struct {
+	//subject of the authenticated principal
+	Subject   string
+	RequestID string
+}
+
+type actorKey struct{}
+
+// returns ctx carrying actor, changes made with ctx are attributed to it
+func WithActor(ctx context.Context, actor Actor) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// returns the actor of ctx, changes without actor are attributed to "system",
+// e.g. changes by the seed of the in-memory store
+func ActorFrom(ctx context.Context) Actor {
+	actor, _ := ctx.Value(actorKey{}).(Actor)
+	if actor.Subject == "" {
+		actor.Subject = "system"
+	}
+	return actor
+}
+
+// returns the id of an attendance in the audit log
+func AttendanceEntityID(eventID, employeeID int) string {
+	return fmt.Sprintf("%d/%d", eventID, employeeID)
+}
+
+// returns the audit entry of a change by the actor of ctx. before is nil if the entity was created and after if
+// it was deleted, updates only record the fields that changed
+func NewAuditEntry(ctx context.Context, action, entity, entityID string, before, after any) (models.AuditEntry, error) {
+	actor := ActorFrom(ctx)
+	entry := models.AuditEntry{Actor: actor.Subject, Action: action, Entity: entity, EntityID: entityID, RequestID: actor.RequestID}
+	var err error
+	if entry.Before, err = auditFields(before); err != nil {
+		return entry, err
+	}
+	if entry.After, err = auditFields(after); err != nil {
+		return entry, err
+	}
+	if entry.Before == nil || entry.After == nil {
+		return entry, nil
+	}
+
+	//drop the fields that didn't change from both sides
+	for field, value := range entry.Before {
+		if bytes.Equal(value, entry.After[field]) {
+			delete(entry.Before, field)
+			delete(entry.After, field)
+		}
+	}
+	return entry, nil
+}
+
+// returns the JSON fields of v, or nil if v is nil
+func auditFields(v any) (map[string]json.RawMessage, error) {
+	if v == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	return fields, json.Unmarshal(data, &fields)
+}
+
+// filter of audit log queries, zero values don't filter
+type AuditFilter struct {
+	Entity   string
+	EntityID string
+}
+
+type AuditRepository interface {
+	// returns the entries matching filter in the order they were recorded
+	ListAuditEntries(ctx context.Context, filter AuditFilter, page Page) ([]models.AuditEntry, error)
+	CountAuditEntries(ctx context.Context, filter AuditFilter) (int, error)
+}
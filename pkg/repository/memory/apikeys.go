@@ -0,0 +1,53 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/mtp721/micobo-assignment/pkg/models"
+	"github.com/mtp721/micobo-assignment/pkg/repository"
+)
+
+func (s *Store) GetAPIKeyByHash(ctx context.Context, hash string) (models.APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, key := range s.apiKeys {
+		if key.Hash == hash {
+			return key, nil
+		}
+	}
+	return models.APIKey{}, repository.ErrNotFound
+}
+
+func (s *Store) CreateAPIKey(ctx context.Context, key models.APIKey) (models.APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.apiKeys {
+		if existing.Hash == key.Hash {
+			return models.APIKey{}, repository.ErrConflict
+		}
+	}
+	s.lastAPIKeyID++
+	key.ID = s.lastAPIKeyID
+	key.CreatedAt = s.Now().UTC()
+	key.RevokedAt = nil
+	s.apiKeys[key.ID] = key
+	return key, nil
+}
+
+func (s *Store) RevokeAPIKey(ctx context.Context, id int) (models.APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.apiKeys[id]
+	if !ok {
+		return key, repository.ErrNotFound
+	}
+	if key.RevokedAt == nil {
+		now := s.Now().UTC()
+		key.RevokedAt = &now
+		s.apiKeys[id] = key
+	}
+	return key, nil
+}
@@ -0,0 +1,133 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/mtp721/micobo-assignment/pkg/models"
+	"github.com/mtp721/micobo-assignment/pkg/repository"
+)
+
+// returns the attendances of the event matching filter ordered by employee id
+func (s *Store) eventAttendances(eventID int, filter repository.AttendeeFilter) []models.Attendance {
+	var attendances []models.Attendance
+	for key, attendance := range s.attendances {
+		if key.eventID != eventID {
+			continue
+		}
+		if filter.Accommodation != nil && attendance.Accommodation != *filter.Accommodation {
+			continue
+		}
+		if !filter.IncludeDeleted && s.employees[key.employeeID].DeletedAt != nil {
+			continue
+		}
+		attendances = append(attendances, attendance)
+	}
+	sortBy(attendances, func(a, b models.Attendance) int { return a.EmployeeID - b.EmployeeID })
+	return attendances
+}
+
+func (s *Store) ListAttendees(ctx context.Context, eventID int, filter repository.AttendeeFilter, page repository.Page) ([]models.EventAttendee, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var attendees []models.EventAttendee
+	for _, attendance := range s.eventAttendances(eventID, filter) {
+		if attendance.EmployeeID <= page.Cursor {
+			continue
+		}
+		attendees = append(attendees, models.EventAttendee{
+			Employee:      s.employees[attendance.EmployeeID],
+			Accommodation: attendance.Accommodation,
+			RegisteredAt:  attendance.RegisteredAt,
+			Status:        attendance.Status,
+		})
+	}
+	return limit(attendees, page.Limit), nil
+}
+
+func (s *Store) CountAttendees(ctx context.Context, eventID int, filter repository.AttendeeFilter) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.eventAttendances(eventID, filter)), nil
+}
+
+func (s *Store) ListEmployeeEvents(ctx context.Context, employeeID int, filter repository.EventFilter) ([]models.EmployeeEvent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.employees[employeeID]; !ok {
+		return nil, &repository.NotFoundError{Entity: "employee", ID: employeeID}
+	}
+	var events []models.EmployeeEvent
+	for key, attendance := range s.attendances {
+		event := s.events[key.eventID]
+		if key.employeeID != employeeID || !inRange(event.Date, filter) {
+			continue
+		}
+		events = append(events, models.EmployeeEvent{
+			Event:         event,
+			Accommodation: attendance.Accommodation,
+			RegisteredAt:  attendance.RegisteredAt,
+			Status:        attendance.Status,
+		})
+	}
+	sortBy(events, func(a, b models.EmployeeEvent) int { return compareEvents(a.Event, b.Event) })
+	return events, nil
+}
+
+func (s *Store) CreateAttendance(ctx context.Context, attendance models.Attendance) (models.Attendance, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.events[attendance.EventID]; !ok {
+		return attendance, &repository.NotFoundError{Entity: "event", ID: attendance.EventID}
+	}
+	if employee, ok := s.employees[attendance.EmployeeID]; !ok || employee.DeletedAt != nil {
+		return attendance, &repository.NotFoundError{Entity: "employee", ID: attendance.EmployeeID}
+	}
+	key := attendanceKey{attendance.EventID, attendance.EmployeeID}
+	if _, ok := s.attendances[key]; ok {
+		return attendance, repository.ErrConflict
+	}
+
+	attendance.RegisteredAt = s.Now().UTC()
+	attendance.Status = models.AttendanceRegistered
+	s.attendances[key] = attendance
+	entityID := repository.AttendanceEntityID(key.eventID, key.employeeID)
+	return attendance, s.recordAudit(ctx, repository.ActionCreate, repository.EntityAttendance, entityID, nil, attendance)
+}
+
+func (s *Store) UpdateAttendance(ctx context.Context, eventID, employeeID int, accommodation *bool) (models.Attendance, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := attendanceKey{eventID, employeeID}
+	before, ok := s.attendances[key]
+	if !ok {
+		return before, repository.ErrNotFound
+	}
+	attendance := before
+	if accommodation == nil {
+		attendance.Accommodation = !attendance.Accommodation
+	} else {
+		attendance.Accommodation = *accommodation
+	}
+	s.attendances[key] = attendance
+	entityID := repository.AttendanceEntityID(eventID, employeeID)
+	return attendance, s.recordAudit(ctx, repository.ActionUpdate, repository.EntityAttendance, entityID, before, attendance)
+}
+
+func (s *Store) DeleteAttendance(ctx context.Context, eventID, employeeID int) (models.Attendance, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := attendanceKey{eventID, employeeID}
+	attendance, ok := s.attendances[key]
+	if !ok {
+		return attendance, repository.ErrNotFound
+	}
+	delete(s.attendances, key)
+	entityID := repository.AttendanceEntityID(eventID, employeeID)
+	return attendance, s.recordAudit(ctx, repository.ActionDelete, repository.EntityAttendance, entityID, attendance, nil)
+}
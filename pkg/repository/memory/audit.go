@@ -0,0 +1,52 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/mtp721/micobo-assignment/pkg/models"
+	"github.com/mtp721/micobo-assignment/pkg/repository"
+)
+
+// appends the change of an entity to the audit log, the store must be locked for writing
+func (s *Store) recordAudit(ctx context.Context, action, entity, entityID string, before, after any) error {
+	entry, err := repository.NewAuditEntry(ctx, action, entity, entityID, before, after)
+	if err != nil {
+		return err
+	}
+	entry.ID = len(s.auditLog) + 1
+	entry.OccurredAt = s.Now().UTC()
+	s.auditLog = append(s.auditLog, entry)
+	return nil
+}
+
+// returns true if the entry matches filter
+func matchesAudit(entry models.AuditEntry, filter repository.AuditFilter) bool {
+	return (filter.Entity == "" || entry.Entity == filter.Entity) && (filter.EntityID == "" || entry.EntityID == filter.EntityID)
+}
+
+func (s *Store) ListAuditEntries(ctx context.Context, filter repository.AuditFilter, page repository.Page) ([]models.AuditEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var entries []models.AuditEntry
+	//entries are appended in the order of their ids
+	for _, entry := range s.auditLog {
+		if entry.ID > page.Cursor && matchesAudit(entry, filter) {
+			entries = append(entries, entry)
+		}
+	}
+	return limit(entries, page.Limit), nil
+}
+
+func (s *Store) CountAuditEntries(ctx context.Context, filter repository.AuditFilter) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	count := 0
+	for _, entry := range s.auditLog {
+		if matchesAudit(entry, filter) {
+			count++
+		}
+	}
+	return count, nil
+}
@@ -0,0 +1,177 @@
+package memory
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/mtp721/micobo-assignment/pkg/models"
+	"github.com/mtp721/micobo-assignment/pkg/repository"
+)
+
+// returns true if the employee matches filter
+func matchesEmployee(employee models.Employee, filter repository.EmployeeFilter) bool {
+	name := strings.ToLower(filter.Name)
+	return (filter.IncludeDeleted || employee.DeletedAt == nil) &&
+		(name == "" || strings.Contains(strings.ToLower(employee.FirstName), name) ||
+			strings.Contains(strings.ToLower(employee.LastName), name)) &&
+		(filter.Gender == "" || employee.Gender == filter.Gender) &&
+		(filter.BirthdayFrom.IsZero() || !employee.BirthDay.Before(filter.BirthdayFrom)) &&
+		(filter.BirthdayTo.IsZero() || !employee.BirthDay.After(filter.BirthdayTo))
+}
+
+func (s *Store) ListEmployees(ctx context.Context, filter repository.EmployeeFilter, page repository.Page) ([]models.Employee, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := filter.Sort
+	if len(keys) == 0 {
+		keys = []repository.SortKey{{Field: "id"}}
+	}
+	//like in postgres the page starts after the cursor's row, which must still exist unless only ids are compared
+	cursor, ok := s.employees[page.Cursor]
+	if page.Cursor != 0 && !ok {
+		if len(keys) > 1 || keys[0].Field != "id" {
+			return nil, nil
+		}
+		cursor = models.Employee{ID: page.Cursor}
+	}
+
+	var employees []models.Employee
+	for _, employee := range s.employees {
+		if !matchesEmployee(employee, filter) {
+			continue
+		}
+		if page.Cursor != 0 && compareEmployees(employee, cursor, keys) <= 0 {
+			continue
+		}
+		employees = append(employees, employee)
+	}
+	sortBy(employees, func(a, b models.Employee) int { return compareEmployees(a, b, keys) })
+	return limit(employees, page.Limit), nil
+}
+
+func (s *Store) CountEmployees(ctx context.Context, filter repository.EmployeeFilter) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var count int
+	for _, employee := range s.employees {
+		if matchesEmployee(employee, filter) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s *Store) GetEmployee(ctx context.Context, id int) (models.Employee, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	employee, ok := s.employees[id]
+	if !ok || employee.DeletedAt != nil {
+		return models.Employee{}, repository.ErrNotFound
+	}
+	return employee, nil
+}
+
+func (s *Store) CreateEmployee(ctx context.Context, employee models.Employee) (models.Employee, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastEmployeeID++
+	employee.ID = s.lastEmployeeID
+	s.employees[employee.ID] = employee
+	return employee, s.recordAudit(ctx, repository.ActionCreate, repository.EntityEmployee, strconv.Itoa(employee.ID), nil, employee)
+}
+
+func (s *Store) UpdateEmployee(ctx context.Context, employee models.Employee) (models.Employee, error) {
+	return s.ModifyEmployee(ctx, employee.ID, func(models.Employee) (models.Employee, error) {
+		return employee, nil
+	})
+}
+
+func (s *Store) ModifyEmployee(ctx context.Context, id int, modify func(models.Employee) (models.Employee, error)) (models.Employee, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	before, ok := s.employees[id]
+	if !ok || before.DeletedAt != nil {
+		return models.Employee{}, repository.ErrNotFound
+	}
+	employee, err := modify(before)
+	if err != nil {
+		return models.Employee{}, err
+	}
+	employee.ID = id
+	//the deletion mark is only changed by DeleteEmployee and RestoreEmployee
+	employee.DeletedAt = nil
+	s.employees[employee.ID] = employee
+	return employee, s.recordAudit(ctx, repository.ActionUpdate, repository.EntityEmployee, strconv.Itoa(employee.ID), before, employee)
+}
+
+func (s *Store) DeleteEmployee(ctx context.Context, id int) (models.Employee, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	before, ok := s.employees[id]
+	if !ok || before.DeletedAt != nil {
+		return models.Employee{}, repository.ErrNotFound
+	}
+	employee := before
+	now := s.Now().UTC()
+	employee.DeletedAt = &now
+	s.employees[id] = employee
+	return employee, s.recordAudit(ctx, repository.ActionDelete, repository.EntityEmployee, strconv.Itoa(id), before, employee)
+}
+
+func (s *Store) RestoreEmployee(ctx context.Context, id int) (models.Employee, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	before, ok := s.employees[id]
+	if !ok {
+		return before, repository.ErrNotFound
+	}
+	if before.DeletedAt == nil {
+		return before, nil
+	}
+	employee := before
+	employee.DeletedAt = nil
+	s.employees[id] = employee
+	return employee, s.recordAudit(ctx, repository.ActionRestore, repository.EntityEmployee, strconv.Itoa(id), before, employee)
+}
+
+// deletes the employee together with their attendances and removes their data from the audit log
+func (s *Store) PurgeEmployee(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.employees[id]; !ok {
+		return repository.ErrNotFound
+	}
+	delete(s.employees, id)
+	var attendances []models.Attendance
+	for key, attendance := range s.attendances {
+		if key.employeeID == id {
+			delete(s.attendances, key)
+			attendances = append(attendances, attendance)
+		}
+	}
+	//the deletions are recorded in the order of the events
+	sortBy(attendances, func(a, b models.Attendance) int { return a.EventID - b.EventID })
+	for _, attendance := range attendances {
+		attendanceID := repository.AttendanceEntityID(attendance.EventID, attendance.EmployeeID)
+		if err := s.recordAudit(ctx, repository.ActionDelete, repository.EntityAttendance, attendanceID, attendance, nil); err != nil {
+			return err
+		}
+	}
+	entityID := strconv.Itoa(id)
+	for i, entry := range s.auditLog {
+		if entry.Entity == repository.EntityEmployee && entry.EntityID == entityID {
+			s.auditLog[i].Before = nil
+			s.auditLog[i].After = nil
+		}
+	}
+	return s.recordAudit(ctx, repository.ActionPurge, repository.EntityEmployee, entityID, nil, nil)
+}
@@ -0,0 +1,128 @@
+package memory
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/mtp721/micobo-assignment/pkg/models"
+	"github.com/mtp721/micobo-assignment/pkg/repository"
+)
+
+func (s *Store) ListEvents(ctx context.Context, filter repository.EventFilter, page repository.Page) ([]models.Event, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	//events are ordered by date, so the page starts after the date and id of the cursor's event
+	cursor, ok := s.events[page.Cursor]
+	if page.Cursor != 0 && !ok {
+		return nil, nil
+	}
+
+	var events []models.Event
+	for _, event := range s.events {
+		if !inRange(event.Date, filter) {
+			continue
+		}
+		if page.Cursor != 0 && compareEvents(event, cursor) <= 0 {
+			continue
+		}
+		events = append(events, event)
+	}
+	sortBy(events, compareEvents)
+	return limit(events, page.Limit), nil
+}
+
+func (s *Store) CountEvents(ctx context.Context, filter repository.EventFilter) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var count int
+	for _, event := range s.events {
+		if inRange(event.Date, filter) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s *Store) GetEvent(ctx context.Context, id int) (models.Event, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	event, ok := s.events[id]
+	if !ok {
+		return event, repository.ErrNotFound
+	}
+	return event, nil
+}
+
+func (s *Store) CreateEvent(ctx context.Context, event models.Event) (models.Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastEventID++
+	event.ID = s.lastEventID
+	s.events[event.ID] = event
+	return event, s.recordAudit(ctx, repository.ActionCreate, repository.EntityEvent, strconv.Itoa(event.ID), nil, event)
+}
+
+func (s *Store) UpdateEvent(ctx context.Context, event models.Event) (models.Event, error) {
+	return s.ModifyEvent(ctx, event.ID, func(models.Event) (models.Event, error) {
+		return event, nil
+	})
+}
+
+func (s *Store) ModifyEvent(ctx context.Context, id int, modify func(models.Event) (models.Event, error)) (models.Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	before, ok := s.events[id]
+	if !ok {
+		return models.Event{}, repository.ErrNotFound
+	}
+	event, err := modify(before)
+	if err != nil {
+		return models.Event{}, err
+	}
+	event.ID = id
+	s.events[event.ID] = event
+	return event, s.recordAudit(ctx, repository.ActionUpdate, repository.EntityEvent, strconv.Itoa(event.ID), before, event)
+}
+
+func (s *Store) DeleteEvent(ctx context.Context, id int, cascade bool) (models.Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var attendees []attendanceKey
+	active := 0
+	for key := range s.attendances {
+		if key.eventID != id {
+			continue
+		}
+		attendees = append(attendees, key)
+		//deleted employees are hidden from the attendees, so they don't keep the event from being deleted
+		if s.employees[key.employeeID].DeletedAt == nil {
+			active++
+		}
+	}
+	if active > 0 && !cascade {
+		return models.Event{}, &repository.HasAttendeesError{Attendees: active}
+	}
+
+	event, ok := s.events[id]
+	if !ok {
+		return event, repository.ErrNotFound
+	}
+	//the deletions are recorded in the order of the employees
+	sortBy(attendees, func(a, b attendanceKey) int { return a.employeeID - b.employeeID })
+	for _, key := range attendees {
+		attendance := s.attendances[key]
+		delete(s.attendances, key)
+		entityID := repository.AttendanceEntityID(key.eventID, key.employeeID)
+		if err := s.recordAudit(ctx, repository.ActionDelete, repository.EntityAttendance, entityID, attendance, nil); err != nil {
+			return event, err
+		}
+	}
+	delete(s.events, id)
+	return event, s.recordAudit(ctx, repository.ActionDelete, repository.EntityEvent, strconv.Itoa(id), event, nil)
+}
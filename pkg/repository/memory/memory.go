@@ -0,0 +1,122 @@
+// Package memory implements the repositories in memory, for running the API without a database.
+// Data is lost when the process exits.
+package memory
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mtp721/micobo-assignment/pkg/models"
+	"github.com/mtp721/micobo-assignment/pkg/repository"
+)
+
+// identifies the attendance of an employee at an event
+type attendanceKey struct {
+	eventID    int
+	employeeID int
+}
+
+// Store implements the employee, event and attendance repositories in maps guarded by a mutex.
+// It behaves like the postgres store, ids are assigned in ascending order starting at 1
+type Store struct {
+	mu             sync.RWMutex
+	employees      map[int]models.Employee
+	events         map[int]models.Event
+	attendances    map[attendanceKey]models.Attendance
+	apiKeys        map[int]models.APIKey
+	auditLog       []models.AuditEntry
+	lastEmployeeID int
+	lastEventID    int
+	lastAPIKeyID   int
+	//Now returns the current time, it is used as registration time of attendances
+	Now func() time.Time
+}
+
+var (
+	_ repository.EmployeeRepository   = (*Store)(nil)
+	_ repository.EventRepository      = (*Store)(nil)
+	_ repository.AttendanceRepository = (*Store)(nil)
+	_ repository.APIKeyRepository     = (*Store)(nil)
+	_ repository.AuditRepository      = (*Store)(nil)
+)
+
+// returns an empty store
+func New() *Store {
+	return &Store{
+		employees:   make(map[int]models.Employee),
+		events:      make(map[int]models.Event),
+		attendances: make(map[attendanceKey]models.Attendance),
+		apiKeys:     make(map[int]models.APIKey),
+		Now:         time.Now,
+	}
+}
+
+// returns the first n values, or all of them if there are less than n
+func limit[T any](values []T, n int) []T {
+	if len(values) > n {
+		return values[:n]
+	}
+	return values
+}
+
+// compares two dates like strings.Compare
+func compareDates(a, b models.Date) int {
+	switch {
+	case a.Before(b):
+		return -1
+	case a.After(b):
+		return 1
+	}
+	return 0
+}
+
+// compares the field of two employees like strings.Compare, fields are named like in JSON
+func compareEmployeeField(a, b models.Employee, field string) int {
+	switch field {
+	case "firstName":
+		return strings.Compare(a.FirstName, b.FirstName)
+	case "lastName":
+		return strings.Compare(a.LastName, b.LastName)
+	case "birthDay":
+		return compareDates(a.BirthDay, b.BirthDay)
+	case "gender":
+		return strings.Compare(a.Gender, b.Gender)
+	}
+	return a.ID - b.ID
+}
+
+// compares two employees in the order of keys
+func compareEmployees(a, b models.Employee, keys []repository.SortKey) int {
+	for _, key := range keys {
+		c := compareEmployeeField(a, b, key.Field)
+		if key.Desc {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// compares two events by date and id
+func compareEvents(a, b models.Event) int {
+	if c := compareDates(a.Date, b.Date); c != 0 {
+		return c
+	}
+	return a.ID - b.ID
+}
+
+// returns true if date is in the range of filter
+func inRange(date models.Date, filter repository.EventFilter) bool {
+	return (filter.From.IsZero() || !date.Before(filter.From)) &&
+		(filter.To.IsZero() || !date.After(filter.To)) &&
+		(filter.Before.IsZero() || date.Before(filter.Before))
+}
+
+// sorts values by compare
+func sortBy[T any](values []T, compare func(a, b T) int) {
+	sort.Slice(values, func(i, j int) bool { return compare(values[i], values[j]) < 0 })
+}
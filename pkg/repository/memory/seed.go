@@ -0,0 +1,56 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/mtp721/micobo-assignment/pkg/models"
+)
+
+// employees added by Seed
+var seedEmployees = []models.Employee{
+	{FirstName: "Anna", LastName: "Schmidt", BirthDay: models.Date{Year: 1988, Month: 3, Day: 14}, Gender: "f"},
+	{FirstName: "Max", LastName: "Müller", BirthDay: models.Date{Year: 1992, Month: 11, Day: 2}, Gender: "m"},
+	{FirstName: "Kim", LastName: "Weber", BirthDay: models.Date{Year: 1995, Month: 7, Day: 23}, Gender: "d"},
+	{FirstName: "Lena", LastName: "Fischer", BirthDay: models.Date{Year: 1979, Month: 1, Day: 30}, Gender: "f"},
+}
+
+// events added by Seed, dated this many days from today so there are past and upcoming events
+var seedEvents = []struct {
+	name string
+	days int
+}{
+	{"Kick-off Meeting", -30},
+	{"Summer Party", 14},
+	{"Hackathon", 45},
+}
+
+// adds sample employees, events and attendances to the store
+func (s *Store) Seed(ctx context.Context) error {
+	var employees []models.Employee
+	for _, employee := range seedEmployees {
+		employee, err := s.CreateEmployee(ctx, employee)
+		if err != nil {
+			return err
+		}
+		employees = append(employees, employee)
+	}
+
+	today := s.Now()
+	for i, seed := range seedEvents {
+		event, err := s.CreateEvent(ctx, models.Event{Name: seed.name, Date: models.DateOf(today.AddDate(0, 0, seed.days))})
+		if err != nil {
+			return err
+		}
+		//every event gets a different subset of the employees
+		for j, employee := range employees {
+			if (i+j)%2 != 0 {
+				continue
+			}
+			attendance := models.Attendance{EventID: event.ID, EmployeeID: employee.ID, Accommodation: j%3 == 0}
+			if _, err := s.CreateAttendance(ctx, attendance); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
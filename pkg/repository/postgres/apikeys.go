@@ -0,0 +1,42 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/mtp721/micobo-assignment/pkg/models"
+)
+
+// columns of the api_keys table in the order they are scanned by scanAPIKey
+const apiKeyColumns = "id, name, key_hash, role, employee_id, created_at, revoked_at"
+
+func scanAPIKey(row scanner, key *models.APIKey) error {
+	return row.Scan(&key.ID, &key.Name, &key.Hash, &key.Role, &key.EmployeeID, &key.CreatedAt, &key.RevokedAt)
+}
+
+func (s *Store) GetAPIKeyByHash(ctx context.Context, hash string) (models.APIKey, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var key models.APIKey
+	row := s.db.QueryRowContext(ctx, "SELECT "+apiKeyColumns+" FROM api_keys WHERE key_hash = $1", hash)
+	return key, wrapError(ctx, scanAPIKey(row, &key))
+}
+
+func (s *Store) CreateAPIKey(ctx context.Context, key models.APIKey) (models.APIKey, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	row := s.db.QueryRowContext(ctx, "INSERT INTO api_keys (name, key_hash, role, employee_id) VALUES ($1, $2, $3, $4) RETURNING "+apiKeyColumns,
+		key.Name, key.Hash, key.Role, key.EmployeeID)
+	var created models.APIKey
+	return created, wrapError(ctx, scanAPIKey(row, &created))
+}
+
+func (s *Store) RevokeAPIKey(ctx context.Context, id int) (models.APIKey, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var key models.APIKey
+	row := s.db.QueryRowContext(ctx, "UPDATE api_keys SET revoked_at = COALESCE(revoked_at, now()) WHERE id = $1 RETURNING "+apiKeyColumns, id)
+	return key, wrapError(ctx, scanAPIKey(row, &key))
+}
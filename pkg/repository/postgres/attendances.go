@@ -0,0 +1,241 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/mtp721/micobo-assignment/pkg/models"
+	"github.com/mtp721/micobo-assignment/pkg/repository"
+)
+
+// columns of the attendances table in the order they are scanned by scanAttendance
+const attendanceColumns = "event_id, employee_id, accommodation, registered_at, status"
+
+func scanAttendance(row scanner, attendance *models.Attendance) error {
+	return row.Scan(&attendance.EventID, &attendance.EmployeeID, &attendance.Accommodation,
+		&attendance.RegisteredAt, &attendance.Status)
+}
+
+// deletes the attendances with id in column, event_id or employee_id, and records their deletion in the audit log
+func deleteAttendances(ctx context.Context, tx *sql.Tx, column string, id int) error {
+	rows, err := tx.QueryContext(ctx, "DELETE FROM attendances WHERE "+column+" = $1 RETURNING "+attendanceColumns, id)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	var attendances []models.Attendance
+	for rows.Next() {
+		var attendance models.Attendance
+		if err := scanAttendance(rows, &attendance); err != nil {
+			return err
+		}
+		attendances = append(attendances, attendance)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	//the rows are read completely before the connection of the transaction is used for the audit entries
+	for _, attendance := range attendances {
+		entityID := repository.AttendanceEntityID(attendance.EventID, attendance.EmployeeID)
+		if err := insertAudit(ctx, tx, repository.ActionDelete, repository.EntityAttendance, entityID, attendance, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// returns the conditions selecting the attendances matching filter, or "" if all match.
+// The query must join the employees of the attendances
+func attendeeCondition(filter repository.AttendeeFilter) string {
+	var conditions []string
+	if !filter.IncludeDeleted {
+		conditions = append(conditions, "AND employees.deleted_at IS NULL")
+	}
+	if filter.Accommodation != nil {
+		conditions = append(conditions, fmt.Sprintf("AND accommodation = %t", *filter.Accommodation))
+	}
+	return strings.Join(conditions, " ")
+}
+
+func (s *Store) ListAttendees(ctx context.Context, eventID int, filter repository.AttendeeFilter, page repository.Page) ([]models.EventAttendee, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var attendees []models.EventAttendee
+
+	cursor, args := cursorCondition(page, "AND id > $%d", []any{eventID})
+	limit, args := limitClause(page, args)
+
+	query := fmt.Sprintf(`SELECT id, first_name, last_name, birthday, gender, deleted_at, accommodation, registered_at, status FROM employees JOIN attendances 
+		ON attendances.employee_id = employees.id WHERE attendances.event_id = $1 %s %s ORDER BY id%s`, attendeeCondition(filter), cursor, limit)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, wrapError(ctx, err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var attendee models.EventAttendee
+		if err := rows.Scan(&attendee.ID, &attendee.FirstName, &attendee.LastName, &attendee.BirthDay, &attendee.Gender, &attendee.DeletedAt,
+			&attendee.Accommodation, &attendee.RegisteredAt, &attendee.Status); err != nil {
+			return nil, err
+		}
+		attendees = append(attendees, attendee)
+	}
+	return attendees, wrapError(ctx, rows.Err())
+}
+
+func (s *Store) CountAttendees(ctx context.Context, eventID int, filter repository.AttendeeFilter) (int, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var count int
+	query := fmt.Sprintf(`SELECT COUNT(*) FROM attendances JOIN employees ON attendances.employee_id = employees.id
+		WHERE attendances.event_id = $1 %s`, attendeeCondition(filter))
+	row := s.db.QueryRowContext(ctx, query, eventID)
+	return count, wrapError(ctx, row.Scan(&count))
+}
+
+func (s *Store) ListEmployeeEvents(ctx context.Context, employeeID int, filter repository.EventFilter) ([]models.EmployeeEvent, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var events []models.EmployeeEvent
+
+	conditions, args := eventConditions(filter, []any{employeeID})
+	var dateQuery string
+	for _, condition := range conditions {
+		dateQuery += " AND " + condition
+	}
+
+	query := fmt.Sprintf(`SELECT id, name, date, accommodation, registered_at, status FROM events JOIN attendances
+		ON attendances.event_id = events.id WHERE attendances.employee_id = $1%s ORDER BY date, id`, dateQuery)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, wrapError(ctx, err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var event models.EmployeeEvent
+		if err := rows.Scan(&event.ID, &event.Name, &event.Date, &event.Accommodation, &event.RegisteredAt, &event.Status); err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil || len(events) > 0 {
+		return events, wrapError(ctx, err)
+	}
+
+	//without registrations the employee is looked up, deleted employees keep their history
+	var exists bool
+	row := s.db.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM employees WHERE id = $1)", employeeID)
+	if err := row.Scan(&exists); err != nil {
+		return nil, wrapError(ctx, err)
+	}
+	if !exists {
+		return nil, &repository.NotFoundError{Entity: "employee", ID: employeeID}
+	}
+	return nil, nil
+}
+
+func (s *Store) CreateAttendance(ctx context.Context, attendance models.Attendance) (models.Attendance, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return attendance, wrapError(ctx, err)
+	}
+	defer tx.Rollback()
+
+	//Check that both event and employee exist so unknown ids can be reported
+	var eventExists, employeeExists bool
+	row := tx.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM events WHERE id = $1), EXISTS(SELECT 1 FROM employees WHERE id = $2 AND deleted_at IS NULL)`,
+		attendance.EventID, attendance.EmployeeID)
+	if err := row.Scan(&eventExists, &employeeExists); err != nil {
+		return attendance, wrapError(ctx, err)
+	}
+	if !eventExists {
+		return attendance, &repository.NotFoundError{Entity: "event", ID: attendance.EventID}
+	}
+	if !employeeExists {
+		return attendance, &repository.NotFoundError{Entity: "employee", ID: attendance.EmployeeID}
+	}
+
+	row = tx.QueryRowContext(ctx, `INSERT INTO attendances (event_id, employee_id, accommodation) VALUES ($1, $2, $3)
+		RETURNING `+attendanceColumns, attendance.EventID, attendance.EmployeeID, attendance.Accommodation)
+	if err := scanAttendance(row, &attendance); err != nil {
+		return attendance, wrapError(ctx, err)
+	}
+	entityID := repository.AttendanceEntityID(attendance.EventID, attendance.EmployeeID)
+	if err := insertAudit(ctx, tx, repository.ActionCreate, repository.EntityAttendance, entityID, nil, attendance); err != nil {
+		return attendance, wrapError(ctx, err)
+	}
+	return attendance, wrapError(ctx, tx.Commit())
+}
+
+func (s *Store) UpdateAttendance(ctx context.Context, eventID, employeeID int, accommodation *bool) (models.Attendance, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var before, attendance models.Attendance
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return attendance, wrapError(ctx, err)
+	}
+	defer tx.Rollback()
+
+	//the row is locked so the audit log has the values it was changed from
+	row := tx.QueryRowContext(ctx, `SELECT `+attendanceColumns+` FROM attendances WHERE event_id = $1 AND employee_id = $2 FOR UPDATE`,
+		eventID, employeeID)
+	if err := scanAttendance(row, &before); err != nil {
+		return attendance, wrapError(ctx, err)
+	}
+	if accommodation == nil {
+		row = tx.QueryRowContext(ctx, `UPDATE attendances SET accommodation = NOT accommodation WHERE event_id = $1 AND employee_id = $2
+			RETURNING `+attendanceColumns, eventID, employeeID)
+	} else {
+		row = tx.QueryRowContext(ctx, `UPDATE attendances SET accommodation = $1 WHERE event_id = $2 AND employee_id = $3
+			RETURNING `+attendanceColumns, *accommodation, eventID, employeeID)
+	}
+	if err := scanAttendance(row, &attendance); err != nil {
+		return attendance, wrapError(ctx, err)
+	}
+	entityID := repository.AttendanceEntityID(eventID, employeeID)
+	if err := insertAudit(ctx, tx, repository.ActionUpdate, repository.EntityAttendance, entityID, before, attendance); err != nil {
+		return attendance, wrapError(ctx, err)
+	}
+	return attendance, wrapError(ctx, tx.Commit())
+}
+
+func (s *Store) DeleteAttendance(ctx context.Context, eventID, employeeID int) (models.Attendance, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var attendance models.Attendance
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return attendance, wrapError(ctx, err)
+	}
+	defer tx.Rollback()
+
+	row := tx.QueryRowContext(ctx, `DELETE FROM attendances WHERE event_id = $1 AND employee_id = $2
+		RETURNING `+attendanceColumns, eventID, employeeID)
+	if err := scanAttendance(row, &attendance); err != nil {
+		return attendance, wrapError(ctx, err)
+	}
+	entityID := repository.AttendanceEntityID(eventID, employeeID)
+	if err := insertAudit(ctx, tx, repository.ActionDelete, repository.EntityAttendance, entityID, attendance, nil); err != nil {
+		return attendance, wrapError(ctx, err)
+	}
+	return attendance, wrapError(ctx, tx.Commit())
+}
@@ -0,0 +1,124 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/mtp721/micobo-assignment/pkg/models"
+	"github.com/mtp721/micobo-assignment/pkg/repository"
+)
+
+// columns of the audit_log table in the order they are scanned by scanAuditEntry
+const auditColumns = "id, occurred_at, actor, action, entity, entity_id, before, after, COALESCE(request_id, '')"
+
+func scanAuditEntry(row scanner, entry *models.AuditEntry) error {
+	var before, after []byte
+	err := row.Scan(&entry.ID, &entry.OccurredAt, &entry.Actor, &entry.Action, &entry.Entity, &entry.EntityID,
+		&before, &after, &entry.RequestID)
+	if err != nil {
+		return err
+	}
+	if before != nil {
+		if err := json.Unmarshal(before, &entry.Before); err != nil {
+			return err
+		}
+	}
+	if after != nil {
+		return json.Unmarshal(after, &entry.After)
+	}
+	return nil
+}
+
+// returns fields as JSON for a JSONB column, or nil for NULL
+func jsonColumn(fields map[string]json.RawMessage) (any, error) {
+	if fields == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(fields)
+	return string(data), err
+}
+
+// records the change of an entity in the audit log, it is written in the transaction of the change
+// so it is only recorded if the change is committed
+func insertAudit(ctx context.Context, tx *sql.Tx, action, entity, entityID string, before, after any) error {
+	entry, err := repository.NewAuditEntry(ctx, action, entity, entityID, before, after)
+	if err != nil {
+		return err
+	}
+	beforeJSON, err := jsonColumn(entry.Before)
+	if err != nil {
+		return err
+	}
+	afterJSON, err := jsonColumn(entry.After)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `INSERT INTO audit_log (actor, action, entity, entity_id, before, after, request_id)
+		VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''))`,
+		entry.Actor, entry.Action, entry.Entity, entry.EntityID, beforeJSON, afterJSON, entry.RequestID)
+	return err
+}
+
+// returns the conditions and their arguments selecting the audit entries matching filter
+func auditConditions(filter repository.AuditFilter) ([]string, []any) {
+	var conditions []string
+	var args []any
+	if filter.Entity != "" {
+		args = append(args, filter.Entity)
+		conditions = append(conditions, fmt.Sprintf("entity = $%d", len(args)))
+	}
+	if filter.EntityID != "" {
+		args = append(args, filter.EntityID)
+		conditions = append(conditions, fmt.Sprintf("entity_id = $%d", len(args)))
+	}
+	return conditions, args
+}
+
+func (s *Store) ListAuditEntries(ctx context.Context, filter repository.AuditFilter, page repository.Page) ([]models.AuditEntry, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var entries []models.AuditEntry
+
+	conditions, args := auditConditions(filter)
+	cursor, args := cursorCondition(page, "id > $%d", args)
+	if cursor != "" {
+		conditions = append(conditions, cursor)
+	}
+	limit, args := limitClause(page, args)
+
+	query := "SELECT " + auditColumns + " FROM audit_log" + where(conditions) + " ORDER BY id" + limit
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, wrapError(ctx, err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var entry models.AuditEntry
+		if err := scanAuditEntry(rows, &entry); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, wrapError(ctx, rows.Err())
+}
+
+func (s *Store) CountAuditEntries(ctx context.Context, filter repository.AuditFilter) (int, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var count int
+	conditions, args := auditConditions(filter)
+	row := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM audit_log"+where(conditions), args...)
+	return count, wrapError(ctx, row.Scan(&count))
+}
+
+// returns the id of an employee or event in the audit log
+func entityID(id int) string {
+	return strconv.Itoa(id)
+}
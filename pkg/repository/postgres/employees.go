@@ -0,0 +1,245 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mtp721/micobo-assignment/pkg/models"
+	"github.com/mtp721/micobo-assignment/pkg/repository"
+)
+
+// columns of the fields employees can be sorted by
+var employeeColumns = map[string]string{
+	"id":        "id",
+	"firstName": "first_name",
+	"lastName":  "last_name",
+	"birthDay":  "birthday",
+	"gender":    "gender",
+}
+
+func scanEmployee(row scanner, employee *models.Employee) error {
+	return row.Scan(&employee.ID, &employee.FirstName, &employee.LastName, &employee.BirthDay, &employee.Gender, &employee.DeletedAt)
+}
+
+// returns the conditions and their arguments selecting the employees matching filter
+func employeeConditions(filter repository.EmployeeFilter) ([]string, []any) {
+	var conditions []string
+	var args []any
+	if !filter.IncludeDeleted {
+		conditions = append(conditions, "deleted_at IS NULL")
+	}
+	if filter.Name != "" {
+		args = append(args, "%"+escapeLike(filter.Name)+"%")
+		conditions = append(conditions, fmt.Sprintf("(first_name ILIKE $%[1]d OR last_name ILIKE $%[1]d)", len(args)))
+	}
+	if filter.Gender != "" {
+		args = append(args, filter.Gender)
+		conditions = append(conditions, fmt.Sprintf("gender = $%d", len(args)))
+	}
+	if !filter.BirthdayFrom.IsZero() {
+		args = append(args, filter.BirthdayFrom)
+		conditions = append(conditions, fmt.Sprintf("birthday >= $%d", len(args)))
+	}
+	if !filter.BirthdayTo.IsZero() {
+		args = append(args, filter.BirthdayTo)
+		conditions = append(conditions, fmt.Sprintf("birthday <= $%d", len(args)))
+	}
+	return conditions, args
+}
+
+func (s *Store) ListEmployees(ctx context.Context, filter repository.EmployeeFilter, page repository.Page) ([]models.Employee, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var employees []models.Employee
+	keys := filter.Sort
+	if len(keys) == 0 {
+		keys = []repository.SortKey{{Field: "id"}}
+	}
+
+	conditions, args := employeeConditions(filter)
+	cursor, args := cursorCondition(page, keysetAfter("employees", keys, employeeColumns), args)
+	if cursor != "" {
+		conditions = append(conditions, cursor)
+	}
+	limit, args := limitClause(page, args)
+
+	query := "SELECT * FROM employees" + where(conditions) + orderBy(keys, employeeColumns) + limit
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, wrapError(ctx, err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var employee models.Employee
+		if err := scanEmployee(rows, &employee); err != nil {
+			return nil, err
+		}
+		employees = append(employees, employee)
+	}
+	return employees, wrapError(ctx, rows.Err())
+}
+
+func (s *Store) CountEmployees(ctx context.Context, filter repository.EmployeeFilter) (int, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var count int
+	conditions, args := employeeConditions(filter)
+	row := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM employees"+where(conditions), args...)
+	return count, wrapError(ctx, row.Scan(&count))
+}
+
+func (s *Store) GetEmployee(ctx context.Context, id int) (models.Employee, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var employee models.Employee
+	row := s.db.QueryRowContext(ctx, "SELECT * FROM employees WHERE id = $1 AND deleted_at IS NULL", id)
+	return employee, wrapError(ctx, scanEmployee(row, &employee))
+}
+
+func (s *Store) CreateEmployee(ctx context.Context, employee models.Employee) (models.Employee, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return employee, wrapError(ctx, err)
+	}
+	defer tx.Rollback()
+
+	row := tx.QueryRowContext(ctx, `INSERT INTO employees (first_name, last_name, birthday, gender) VALUES ($1, $2, $3, $4) RETURNING id`,
+		employee.FirstName, employee.LastName, employee.BirthDay, employee.Gender)
+	if err := row.Scan(&employee.ID); err != nil {
+		return employee, wrapError(ctx, err)
+	}
+	if err := insertAudit(ctx, tx, repository.ActionCreate, repository.EntityEmployee, entityID(employee.ID), nil, employee); err != nil {
+		return employee, wrapError(ctx, err)
+	}
+	return employee, wrapError(ctx, tx.Commit())
+}
+
+func (s *Store) UpdateEmployee(ctx context.Context, employee models.Employee) (models.Employee, error) {
+	return s.ModifyEmployee(ctx, employee.ID, func(models.Employee) (models.Employee, error) {
+		return employee, nil
+	})
+}
+
+func (s *Store) ModifyEmployee(ctx context.Context, id int, modify func(models.Employee) (models.Employee, error)) (models.Employee, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var before, updated models.Employee
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return updated, wrapError(ctx, err)
+	}
+	defer tx.Rollback()
+
+	//the row is locked until the transaction ends, so it can't be changed or deleted between reading and updating it
+	row := tx.QueryRowContext(ctx, "SELECT * FROM employees WHERE id = $1 AND deleted_at IS NULL FOR UPDATE", id)
+	if err := scanEmployee(row, &before); err != nil {
+		return updated, wrapError(ctx, err)
+	}
+	employee, err := modify(before)
+	if err != nil {
+		return updated, err
+	}
+	row = tx.QueryRowContext(ctx, "UPDATE employees SET first_name = $1, last_name = $2, birthday = $3, gender = $4 WHERE id = $5 RETURNING *",
+		employee.FirstName, employee.LastName, employee.BirthDay, employee.Gender, id)
+	//the returned row is scanned to make sure values were updated correctly
+	if err := scanEmployee(row, &updated); err != nil {
+		return updated, wrapError(ctx, err)
+	}
+	if err := insertAudit(ctx, tx, repository.ActionUpdate, repository.EntityEmployee, entityID(updated.ID), before, updated); err != nil {
+		return updated, wrapError(ctx, err)
+	}
+	return updated, wrapError(ctx, tx.Commit())
+}
+
+func (s *Store) DeleteEmployee(ctx context.Context, id int) (models.Employee, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var employee models.Employee
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return employee, wrapError(ctx, err)
+	}
+	defer tx.Rollback()
+
+	row := tx.QueryRowContext(ctx, "UPDATE employees SET deleted_at = now() WHERE id = $1 AND deleted_at IS NULL RETURNING *", id)
+	if err := scanEmployee(row, &employee); err != nil {
+		return employee, wrapError(ctx, err)
+	}
+	before := employee
+	before.DeletedAt = nil
+	if err := insertAudit(ctx, tx, repository.ActionDelete, repository.EntityEmployee, entityID(id), before, employee); err != nil {
+		return employee, wrapError(ctx, err)
+	}
+	return employee, wrapError(ctx, tx.Commit())
+}
+
+func (s *Store) RestoreEmployee(ctx context.Context, id int) (models.Employee, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var before, employee models.Employee
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return employee, wrapError(ctx, err)
+	}
+	defer tx.Rollback()
+
+	row := tx.QueryRowContext(ctx, "SELECT * FROM employees WHERE id = $1 FOR UPDATE", id)
+	if err := scanEmployee(row, &before); err != nil {
+		return employee, wrapError(ctx, err)
+	}
+	//restoring an employee that isn't deleted changes nothing
+	if before.DeletedAt == nil {
+		return before, nil
+	}
+	row = tx.QueryRowContext(ctx, "UPDATE employees SET deleted_at = NULL WHERE id = $1 RETURNING *", id)
+	if err := scanEmployee(row, &employee); err != nil {
+		return employee, wrapError(ctx, err)
+	}
+	if err := insertAudit(ctx, tx, repository.ActionRestore, repository.EntityEmployee, entityID(id), before, employee); err != nil {
+		return employee, wrapError(ctx, err)
+	}
+	return employee, wrapError(ctx, tx.Commit())
+}
+
+func (s *Store) PurgeEmployee(ctx context.Context, id int) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return wrapError(ctx, err)
+	}
+	defer tx.Rollback()
+
+	//the employee is locked before the attendances are deleted so they can't be registered for events meanwhile
+	row := tx.QueryRowContext(ctx, "SELECT id FROM employees WHERE id = $1 FOR UPDATE", id)
+	if err := row.Scan(&id); err != nil {
+		return wrapError(ctx, err)
+	}
+	//the attendances are deleted explicitly instead of by the foreign key so their deletion is audited
+	if err := deleteAttendances(ctx, tx, "employee_id", id); err != nil {
+		return wrapError(ctx, err)
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM employees WHERE id = $1", id); err != nil {
+		return wrapError(ctx, err)
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE audit_log SET before = NULL, after = NULL WHERE entity = $1 AND entity_id = $2",
+		repository.EntityEmployee, entityID(id)); err != nil {
+		return wrapError(ctx, err)
+	}
+	if err := insertAudit(ctx, tx, repository.ActionPurge, repository.EntityEmployee, entityID(id), nil, nil); err != nil {
+		return wrapError(ctx, err)
+	}
+	return wrapError(ctx, tx.Commit())
+}
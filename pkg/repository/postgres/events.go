@@ -0,0 +1,181 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mtp721/micobo-assignment/pkg/models"
+	"github.com/mtp721/micobo-assignment/pkg/repository"
+)
+
+func scanEvent(row scanner, event *models.Event) error {
+	return row.Scan(&event.ID, &event.Name, &event.Date)
+}
+
+// returns the conditions and their arguments selecting the events in the date range of filter,
+// args are the arguments of the query the conditions are added to
+func eventConditions(filter repository.EventFilter, args []any) ([]string, []any) {
+	var conditions []string
+	for _, bound := range []struct {
+		date     models.Date
+		operator string
+	}{{filter.From, ">="}, {filter.To, "<="}, {filter.Before, "<"}} {
+		if bound.date.IsZero() {
+			continue
+		}
+		args = append(args, bound.date)
+		conditions = append(conditions, fmt.Sprintf("date %s $%d", bound.operator, len(args)))
+	}
+	return conditions, args
+}
+
+func (s *Store) ListEvents(ctx context.Context, filter repository.EventFilter, page repository.Page) ([]models.Event, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var events []models.Event
+
+	conditions, args := eventConditions(filter, nil)
+	//events are ordered by date, so the page starts after the date and id of the cursor's event
+	cursor, args := cursorCondition(page, "(date, id) > (SELECT date, id FROM events WHERE id = $%d)", args)
+	if cursor != "" {
+		conditions = append(conditions, cursor)
+	}
+	limit, args := limitClause(page, args)
+
+	query := "SELECT * FROM events" + where(conditions) + " ORDER BY date, id" + limit
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, wrapError(ctx, err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var event models.Event
+		if err := scanEvent(rows, &event); err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, wrapError(ctx, rows.Err())
+}
+
+func (s *Store) CountEvents(ctx context.Context, filter repository.EventFilter) (int, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var count int
+	conditions, args := eventConditions(filter, nil)
+	row := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM events"+where(conditions), args...)
+	return count, wrapError(ctx, row.Scan(&count))
+}
+
+func (s *Store) GetEvent(ctx context.Context, id int) (models.Event, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var event models.Event
+	row := s.db.QueryRowContext(ctx, "SELECT * FROM events WHERE id = $1", id)
+	return event, wrapError(ctx, scanEvent(row, &event))
+}
+
+func (s *Store) CreateEvent(ctx context.Context, event models.Event) (models.Event, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return event, wrapError(ctx, err)
+	}
+	defer tx.Rollback()
+
+	row := tx.QueryRowContext(ctx, `INSERT INTO events (name, date) VALUES ($1, $2) RETURNING id`, event.Name, event.Date)
+	if err := row.Scan(&event.ID); err != nil {
+		return event, wrapError(ctx, err)
+	}
+	if err := insertAudit(ctx, tx, repository.ActionCreate, repository.EntityEvent, entityID(event.ID), nil, event); err != nil {
+		return event, wrapError(ctx, err)
+	}
+	return event, wrapError(ctx, tx.Commit())
+}
+
+func (s *Store) UpdateEvent(ctx context.Context, event models.Event) (models.Event, error) {
+	return s.ModifyEvent(ctx, event.ID, func(models.Event) (models.Event, error) {
+		return event, nil
+	})
+}
+
+func (s *Store) ModifyEvent(ctx context.Context, id int, modify func(models.Event) (models.Event, error)) (models.Event, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var before, updated models.Event
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return updated, wrapError(ctx, err)
+	}
+	defer tx.Rollback()
+
+	//the row is locked until the transaction ends, so it can't be changed or deleted between reading and updating it
+	row := tx.QueryRowContext(ctx, "SELECT * FROM events WHERE id = $1 FOR UPDATE", id)
+	if err := scanEvent(row, &before); err != nil {
+		return updated, wrapError(ctx, err)
+	}
+	event, err := modify(before)
+	if err != nil {
+		return updated, err
+	}
+	row = tx.QueryRowContext(ctx, "UPDATE events SET name = $1, date = $2 WHERE id = $3 RETURNING *",
+		event.Name, event.Date, id)
+	//the returned row is scanned to make sure values were updated correctly
+	if err := scanEvent(row, &updated); err != nil {
+		return updated, wrapError(ctx, err)
+	}
+	if err := insertAudit(ctx, tx, repository.ActionUpdate, repository.EntityEvent, entityID(updated.ID), before, updated); err != nil {
+		return updated, wrapError(ctx, err)
+	}
+	return updated, wrapError(ctx, tx.Commit())
+}
+
+func (s *Store) DeleteEvent(ctx context.Context, id int, cascade bool) (models.Event, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var event models.Event
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return event, wrapError(ctx, err)
+	}
+	defer tx.Rollback()
+
+	//the event is locked so no employee can be registered between counting and deleting the attendances
+	row := tx.QueryRowContext(ctx, "SELECT id FROM events WHERE id = $1 FOR UPDATE", id)
+	if err := row.Scan(&id); err != nil {
+		return event, wrapError(ctx, err)
+	}
+	if !cascade {
+		//deleted employees are hidden from the attendees, so they don't keep the event from being deleted
+		var attendees int
+		row := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM attendances JOIN employees ON employees.id = employee_id
+			WHERE event_id = $1 AND employees.deleted_at IS NULL`, id)
+		if err := row.Scan(&attendees); err != nil {
+			return event, wrapError(ctx, err)
+		}
+		if attendees > 0 {
+			return event, &repository.HasAttendeesError{Attendees: attendees}
+		}
+	}
+	if err := deleteAttendances(ctx, tx, "event_id", id); err != nil {
+		return event, wrapError(ctx, err)
+	}
+
+	row = tx.QueryRowContext(ctx, "DELETE FROM events WHERE id = $1 RETURNING *", id)
+	if err := scanEvent(row, &event); err != nil {
+		return event, wrapError(ctx, err)
+	}
+	if err := insertAudit(ctx, tx, repository.ActionDelete, repository.EntityEvent, entityID(id), event, nil); err != nil {
+		return event, wrapError(ctx, err)
+	}
+	return event, wrapError(ctx, tx.Commit())
+}
@@ -0,0 +1,150 @@
+// Package postgres implements the repositories on a postgres database using lib/pq.
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/mtp721/micobo-assignment/pkg/repository"
+)
+
+// postgres error codes returned by lib/pq
+const (
+	pqForeignKeyViolation pq.ErrorCode = "23503"
+	pqUniqueViolation     pq.ErrorCode = "23505"
+	pqCheckViolation      pq.ErrorCode = "23514"
+	//class of data exceptions like invalid input syntax or out of range values
+	pqDataException = "22"
+)
+
+// Store implements the employee, event and attendance repositories on the tables
+// employees, events and attendances. Every change is recorded in the audit_log table in the same transaction
+type Store struct {
+	db *sql.DB
+	//QueryTimeout limits the time of every repository call, 0 means no limit besides the caller's context
+	QueryTimeout time.Duration
+}
+
+var (
+	_ repository.EmployeeRepository   = (*Store)(nil)
+	_ repository.EventRepository      = (*Store)(nil)
+	_ repository.AttendanceRepository = (*Store)(nil)
+	_ repository.APIKeyRepository     = (*Store)(nil)
+	_ repository.AuditRepository      = (*Store)(nil)
+)
+
+func New(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// returns ctx limited by the query timeout of the store
+func (s *Store) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.QueryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, s.QueryTimeout)
+}
+
+// implemented by *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// wraps err with the repository error matching it, other errors are returned unchanged.
+// If ctx is done, err is wrapped with the error of ctx as the driver's error doesn't tell why the query was canceled
+func wrapError(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return fmt.Errorf("%w: %v", ctxErr, err)
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: %v", repository.ErrNotFound, err)
+	}
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return err
+	}
+	switch {
+	case pqErr.Code == pqUniqueViolation:
+		return fmt.Errorf("%w: %v", repository.ErrConflict, err)
+	case pqErr.Code == pqForeignKeyViolation:
+		return fmt.Errorf("%w: %v", repository.ErrInvalidReference, err)
+	case pqErr.Code == pqCheckViolation, strings.HasPrefix(string(pqErr.Code), pqDataException):
+		return fmt.Errorf("%w: %v", repository.ErrInvalidValue, err)
+	}
+	return err
+}
+
+// returns the WHERE clause joining conditions, or "" if there are none
+func where(conditions []string) string {
+	if len(conditions) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(conditions, " AND ")
+}
+
+// returns the condition selecting the rows after the cursor of page, or "" for the first page.
+// keyset is the condition with a %d verb for the index of the cursor placeholder
+func cursorCondition(page repository.Page, keyset string, args []any) (string, []any) {
+	if page.Cursor == 0 {
+		return "", args
+	}
+	args = append(args, page.Cursor)
+	return fmt.Sprintf(keyset, len(args)), args
+}
+
+// returns the LIMIT clause of page
+func limitClause(page repository.Page, args []any) (string, []any) {
+	args = append(args, page.Limit)
+	return fmt.Sprintf(" LIMIT $%d", len(args)), args
+}
+
+// returns the ORDER BY clause for keys, fields are mapped to columns by columns
+func orderBy(keys []repository.SortKey, columns map[string]string) string {
+	order := make([]string, len(keys))
+	for i, key := range keys {
+		order[i] = columns[key.Field]
+		if key.Desc {
+			order[i] += " DESC"
+		}
+	}
+	return " ORDER BY " + strings.Join(order, ", ")
+}
+
+// returns the keyset condition for cursorCondition selecting the rows of table
+// that come after the row with the cursor id in the order of keys
+func keysetAfter(table string, keys []repository.SortKey, columns map[string]string) string {
+	if len(keys) == 1 && keys[0].Field == "id" && !keys[0].Desc {
+		return "id > $%d"
+	}
+	//the values of the cursor row are looked up by subqueries on the cursor placeholder
+	cursorValue := func(column string) string {
+		return fmt.Sprintf("(SELECT %s FROM %s WHERE id = $%%[1]d)", column, table)
+	}
+	alternatives := make([]string, len(keys))
+	for i, key := range keys {
+		var parts []string
+		for _, prev := range keys[:i] {
+			parts = append(parts, columns[prev.Field]+" = "+cursorValue(columns[prev.Field]))
+		}
+		operator := " > "
+		if key.Desc {
+			operator = " < "
+		}
+		parts = append(parts, columns[key.Field]+operator+cursorValue(columns[key.Field]))
+		alternatives[i] = "(" + strings.Join(parts, " AND ") + ")"
+	}
+	return "(" + strings.Join(alternatives, " OR ") + ")"
+}
+
+// escapes the wildcards of a LIKE pattern so value is matched literally
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+}
@@ -0,0 +1,160 @@
+// Package repository defines the storage operations the handlers use for employees, events and attendances.
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/mtp721/micobo-assignment/pkg/models"
+)
+
+// kinds of errors returned by repositories, implementations wrap their storage errors with them
+var (
+	ErrNotFound = errors.New("not found")
+	//a row with the same unique key exists already
+	ErrConflict = errors.New("conflict")
+	//a referenced row doesn't exist
+	ErrInvalidReference = errors.New("invalid reference")
+	//a value is rejected by the storage, e.g. by a check constraint
+	ErrInvalidValue = errors.New("invalid value")
+)
+
+// NotFoundError is returned if an entity referenced by id doesn't exist, it matches ErrNotFound
+type NotFoundError struct {
+	Entity string
+	ID     int
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("%s %d not found", e.Entity, e.ID)
+}
+
+func (e *NotFoundError) Is(target error) bool {
+	return target == ErrNotFound
+}
+
+// HasAttendeesError is returned when deleting an event that employees are registered for without cascading
+type HasAttendeesError struct {
+	Attendees int
+}
+
+func (e *HasAttendeesError) Error() string {
+	return fmt.Sprintf("event has %d registered employees", e.Attendees)
+}
+
+// selects a page of a list, rows are paged by keyset on their id
+type Page struct {
+	//maximum number of rows returned
+	Limit int
+	//id of the last row of the previous page, 0 for the first page
+	Cursor int
+}
+
+// sort order on a field of a model, fields are named like in JSON
+type SortKey struct {
+	Field string
+	Desc  bool
+}
+
+// fields employees can be sorted by
+var EmployeeSortFields = []string{"id", "firstName", "lastName", "birthDay", "gender"}
+
+// filter and order of employee lists, zero values don't filter
+type EmployeeFilter struct {
+	//case-insensitive substring of the first or last name
+	Name         string
+	Gender       string
+	BirthdayFrom models.Date
+	BirthdayTo   models.Date
+	//deleted employees are only returned if set
+	IncludeDeleted bool
+	//sort order, the last key must be id so the order is unique
+	Sort []SortKey
+}
+
+// date range of event lists, zero values don't filter
+type EventFilter struct {
+	From models.Date
+	To   models.Date
+	//only events before this date
+	Before models.Date
+}
+
+// filter of the attendees of an event
+type AttendeeFilter struct {
+	//only attendees with this accommodation flag, nil for all
+	Accommodation *bool
+	//deleted employees are only returned if set
+	IncludeDeleted bool
+}
+
+type EmployeeRepository interface {
+	ListEmployees(ctx context.Context, filter EmployeeFilter, page Page) ([]models.Employee, error)
+	CountEmployees(ctx context.Context, filter EmployeeFilter) (int, error)
+	GetEmployee(ctx context.Context, id int) (models.Employee, error)
+	// stores a new employee and returns it with its id
+	CreateEmployee(ctx context.Context, employee models.Employee) (models.Employee, error)
+	// overwrites the employee with the id of employee
+	UpdateEmployee(ctx context.Context, employee models.Employee) (models.Employee, error)
+	// overwrites the employee with the result of modify applied to its current values. The employee is locked
+	// until it is stored so concurrent changes aren't lost, errors of modify are returned unchanged
+	ModifyEmployee(ctx context.Context, id int, modify func(models.Employee) (models.Employee, error)) (models.Employee, error)
+	// marks the employee as deleted and returns it, deleted employees are only listed if filters include them
+	// and can't be updated or registered for events
+	DeleteEmployee(ctx context.Context, id int) (models.Employee, error)
+	// removes the deletion mark of the employee and returns it
+	RestoreEmployee(ctx context.Context, id int) (models.Employee, error)
+	// removes the employee with their attendances and erases their data from the audit log
+	PurgeEmployee(ctx context.Context, id int) error
+}
+
+type EventRepository interface {
+	// returns the events ordered by date
+	ListEvents(ctx context.Context, filter EventFilter, page Page) ([]models.Event, error)
+	CountEvents(ctx context.Context, filter EventFilter) (int, error)
+	GetEvent(ctx context.Context, id int) (models.Event, error)
+	// stores a new event and returns it with its id
+	CreateEvent(ctx context.Context, event models.Event) (models.Event, error)
+	// overwrites the event with the id of event
+	UpdateEvent(ctx context.Context, event models.Event) (models.Event, error)
+	// overwrites the event with the result of modify applied to its current values. The event is locked
+	// until it is stored so concurrent changes aren't lost, errors of modify are returned unchanged
+	ModifyEvent(ctx context.Context, id int, modify func(models.Event) (models.Event, error)) (models.Event, error)
+	// deletes the event with its attendances and returns it. Unless cascade is set *HasAttendeesError is returned
+	// if employees that aren't deleted are registered for it, attendances of deleted employees are always deleted
+	DeleteEvent(ctx context.Context, id int, cascade bool) (models.Event, error)
+}
+
+type AttendanceRepository interface {
+	// returns the employees registered for the event ordered by id
+	ListAttendees(ctx context.Context, eventID int, filter AttendeeFilter, page Page) ([]models.EventAttendee, error)
+	CountAttendees(ctx context.Context, eventID int, filter AttendeeFilter) (int, error)
+	// returns the events the employee is registered for ordered by date, *NotFoundError is returned
+	// if the employee doesn't exist. Deleted employees are found as their history is kept
+	ListEmployeeEvents(ctx context.Context, employeeID int, filter EventFilter) ([]models.EmployeeEvent, error)
+	// registers the employee for the event, *NotFoundError is returned if one of them doesn't exist
+	// and ErrConflict if the employee is registered already
+	CreateAttendance(ctx context.Context, attendance models.Attendance) (models.Attendance, error)
+	// sets the accommodation flag of the attendance, it is toggled if accommodation is nil
+	UpdateAttendance(ctx context.Context, eventID, employeeID int, accommodation *bool) (models.Attendance, error)
+	DeleteAttendance(ctx context.Context, eventID, employeeID int) (models.Attendance, error)
+}
+
+type APIKeyRepository interface {
+	// returns the key with the hash, revoked keys are returned as well
+	GetAPIKeyByHash(ctx context.Context, hash string) (models.APIKey, error)
+	// stores a new key and returns it with its id and creation time
+	CreateAPIKey(ctx context.Context, key models.APIKey) (models.APIKey, error)
+	// marks the key as revoked and returns it, it can't be used to authenticate afterwards
+	RevokeAPIKey(ctx context.Context, id int) (models.APIKey, error)
+}
+
+// Store provides all repositories, implemented by the postgres and the in-memory store
+type Store interface {
+	EmployeeRepository
+	EventRepository
+	AttendanceRepository
+	APIKeyRepository
+	AuditRepository
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"time"
+)
+
+// config of the http server, set with flags or environment variables
+type serverConfig struct {
+	addr              string
+	readTimeout       time.Duration
+	readHeaderTimeout time.Duration
+	writeTimeout      time.Duration
+	idleTimeout       time.Duration
+	//time in-flight requests get to finish after SIGINT or SIGTERM
+	shutdownTimeout time.Duration
+	//TLS is served if both files are set
+	tlsCertFile string
+	tlsKeyFile  string
+}
+
+// registers the flags of the server config on fs, their defaults are read from the environment
+func (c *serverConfig) registerFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.addr, "addr", envOr("ADDR", ":8080"), "address the server listens on (env ADDR)")
+	fs.DurationVar(&c.readTimeout, "read-timeout", envDuration("READ_TIMEOUT", 15*time.Second),
+		"maximum time to read a request including its body (env READ_TIMEOUT)")
+	fs.DurationVar(&c.readHeaderTimeout, "read-header-timeout", envDuration("READ_HEADER_TIMEOUT", 5*time.Second),
+		"maximum time to read the request headers (env READ_HEADER_TIMEOUT)")
+	fs.DurationVar(&c.writeTimeout, "write-timeout", envDuration("WRITE_TIMEOUT", 30*time.Second),
+		"maximum time to write a response (env WRITE_TIMEOUT)")
+	fs.DurationVar(&c.idleTimeout, "idle-timeout", envDuration("IDLE_TIMEOUT", 60*time.Second),
+		"maximum time a keep-alive connection stays idle (env IDLE_TIMEOUT)")
+	fs.DurationVar(&c.shutdownTimeout, "shutdown-timeout", envDuration("SHUTDOWN_TIMEOUT", 30*time.Second),
+		"time in-flight requests get to finish on shutdown (env SHUTDOWN_TIMEOUT)")
+	fs.StringVar(&c.tlsCertFile, "tls-cert", os.Getenv("TLS_CERT_FILE"), "file of the TLS certificate (env TLS_CERT_FILE)")
+	fs.StringVar(&c.tlsKeyFile, "tls-key", os.Getenv("TLS_KEY_FILE"), "file of the TLS private key (env TLS_KEY_FILE)")
+}
+
+// returns the duration in the environment variable key, or fallback if it isn't set
+func envDuration(key string, fallback time.Duration) time.Duration {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		fatal("invalid duration, expected e.g. 30s or 5m", "env", key, "value", value)
+	}
+	return d
+}
+
+// listens on the configured address and serves handler until ctx is done,
+// then waits for in-flight requests to finish before returning
+func serve(ctx context.Context, cfg serverConfig, handler http.Handler) error {
+	if (cfg.tlsCertFile == "") != (cfg.tlsKeyFile == "") {
+		return errors.New("TLS needs both a certificate and a key file")
+	}
+	ln, err := net.Listen("tcp", cfg.addr)
+	if err != nil {
+		return err
+	}
+	return serveListener(ctx, ln, cfg, handler)
+}
+
+func serveListener(ctx context.Context, ln net.Listener, cfg serverConfig, handler http.Handler) error {
+	srv := &http.Server{
+		Handler:           handler,
+		ReadTimeout:       cfg.readTimeout,
+		ReadHeaderTimeout: cfg.readHeaderTimeout,
+		WriteTimeout:      cfg.writeTimeout,
+		IdleTimeout:       cfg.idleTimeout,
+	}
+
+	errc := make(chan error, 1)
+	go func() {
+		if cfg.tlsCertFile != "" {
+			slog.Info("listening", "url", "https://"+ln.Addr().String())
+			errc <- srv.ServeTLS(ln, cfg.tlsCertFile, cfg.tlsKeyFile)
+		} else {
+			slog.Info("listening", "url", "http://"+ln.Addr().String())
+			errc <- srv.Serve(ln)
+		}
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	slog.Info("shutting down, waiting for in-flight requests", "timeout", cfg.shutdownTimeout.String())
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("shutting down server: %w", err)
+	}
+	//Serve returns ErrServerClosed once Shutdown is called
+	if err := <-errc; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}